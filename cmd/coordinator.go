@@ -0,0 +1,119 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cmd
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+
+	"github.com/oncilla/fuzzinator/conf"
+	"github.com/oncilla/fuzzinator/lib"
+)
+
+var (
+	coordinatorFlags  optionFlags
+	coordinatorAddr   string
+	coordinatorGoFuzz string
+)
+
+var coordinatorCmd = &cobra.Command{
+	Use:   "coordinator",
+	Short: "setup the workdir and coordinate go-fuzz workers on other machines",
+	Long: `Sets up the workdir like setup, and runs go-fuzz in coordinator mode. The
+fuzzing binary is served to the workers, which are started with:
+
+  fuzzinator worker <addr>
+
+where addr is the address set by --addr. The go-fuzz coordinator listens on
+--go-fuzz-addr. If its host is unspecified, e.g., ":8745", the workers
+connect to the host they reached the coordinator on.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target, commit, err := targetAndCommit(confFile, args[0])
+		if err != nil {
+			return err
+		}
+		if err := coordinatorFlags.apply(cmd.Flags(), &target); err != nil {
+			return err
+		}
+		applySetupFlags(cmd, &target)
+		lock, err := lockWorkdir(target, commit)
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+		if err := setup(target, commit, freshWorkdir, terminate); err != nil {
+			return err
+		}
+		workdir := lib.TempWorkdir(target.Name, commit)
+		meta, err := lib.ReadWorkdirMeta(workdir)
+		if err != nil {
+			return err
+		}
+		sum, err := lib.FileSHA256(lib.BinaryPath(workdir))
+		if err != nil {
+			return err
+		}
+		info := lib.CoordinatorInfo{
+			Target:       target.Name,
+			Commit:       commit,
+			BuildKey:     meta.BuildKey,
+			BinarySHA256: sum,
+			Coordinator:  coordinatorGoFuzz,
+			Args:         workerArgs(target),
+		}
+		addr, shutdown, err := startServer(coordinatorAddr,
+			lib.CoordinatorHandler(info, lib.BinaryPath(workdir)))
+		if err != nil {
+			return err
+		}
+		defer shutdown()
+		log.Printf("Serving the fuzzing binary to workers, start them with: "+
+			"fuzzinator worker %s", addr)
+		opts := runOpts
+		opts.coordinator = coordinatorGoFuzz
+		return fuzz(target, commit, opts, terminate)
+	},
+}
+
+func init() {
+	coordinatorFlags.register(coordinatorCmd.Flags())
+	addSetupFlags(coordinatorCmd)
+	addLockFlags(coordinatorCmd)
+	addRunFlags(coordinatorCmd)
+	coordinatorCmd.Flags().StringVar(&coordinatorAddr, "addr", "localhost:8746",
+		"address the fuzzing binary is served to the workers on")
+	coordinatorCmd.Flags().StringVar(&coordinatorGoFuzz, "go-fuzz-addr", "localhost:8745",
+		"address the go-fuzz coordinator listens on")
+}
+
+// workerArgs returns the go-fuzz arguments for the workers. The number of
+// procs is chosen by every worker, the dictionary is a local file, and the
+// coverage is dumped by the coordinator.
+func workerArgs(target conf.Target) []string {
+	target.Options.Procs = 0
+	target.Options.Dict = ""
+	target.Options.DumpCover = false
+	return target.FuzzArgs()
+}
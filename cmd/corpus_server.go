@@ -0,0 +1,110 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cmd
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+
+	"github.com/oncilla/fuzzinator/lib"
+)
+
+var (
+	corpusServerAddr string
+	corpusServerDir  string
+)
+
+var corpusServerCmd = &cobra.Command{
+	Use:   "corpus-server",
+	Short: "serve a corpus shared between fuzzing machines",
+	Long: `Serves a corpus that is shared between fuzzing machines. Fuzzing with
+--corpus-server periodically uploads new corpus entries and crashers of the
+workdir, keyed by target and content hash, and pulls the corpus entries
+that other machines uploaded. go-fuzz only reads the corpus when it starts,
+so it is restarted to load pulled entries, at most once per
+--corpus-reload-interval.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		srv := &lib.CorpusServer{Dir: corpusServerDir}
+		log.Printf("Serving corpora in %s on http://%s", corpusServerDir, corpusServerAddr)
+		if err := http.ListenAndServe(corpusServerAddr, srv); err != nil {
+			return xerrors.Errorf("unable to serve corpora: %w", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	corpusServerCmd.Flags().StringVar(&corpusServerAddr, "addr", "localhost:8747",
+		"defines the address the corpora are served on")
+	corpusServerCmd.Flags().StringVar(&corpusServerDir, "dir", lib.DefaultCorpusServerDir(),
+		"defines the directory the corpora are stored in")
+}
+
+// syncCorpus synchronizes the workdir with the corpus server in the interval
+// until stop is closed. Whenever corpus entries were pulled, a value is sent
+// on pulled without blocking. A last synchronization is done after stop is
+// closed, the returned channel is closed when it finished. Failures are
+// logged, they do not abort fuzzing.
+func syncCorpus(client *lib.CorpusClient, workdir string, interval time.Duration,
+	pulled chan<- struct{}, stop <-chan struct{}) <-chan struct{} {
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				syncCorpusOnce(client, workdir)
+				return
+			case <-ticker.C:
+				if syncCorpusOnce(client, workdir).Pulled == 0 {
+					continue
+				}
+				select {
+				case pulled <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return done
+}
+
+func syncCorpusOnce(client *lib.CorpusClient, workdir string) lib.SyncStats {
+	stats, err := client.Sync(workdir)
+	if err != nil {
+		log.Printf("Unable to sync with corpus server: %s", err)
+	}
+	if stats.Uploaded+stats.UploadedCrashers+stats.Pulled > 0 {
+		log.Printf("Corpus server sync: uploaded %d corpus entries and %d crashers, "+
+			"pulled %d corpus entries", stats.Uploaded, stats.UploadedCrashers, stats.Pulled)
+	}
+	return stats
+}
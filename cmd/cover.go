@@ -0,0 +1,301 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+
+	"github.com/oncilla/fuzzinator/conf"
+	"github.com/oncilla/fuzzinator/lib"
+)
+
+const (
+	coverFromWorkdir = "workdir"
+	coverFromCorpus  = "corpus"
+)
+
+var (
+	coverFrom string
+	coverOut  string
+	coverPkgs []string
+)
+
+var coverCmd = &cobra.Command{
+	Use:   "cover",
+	Short: "replay the corpus and report its coverage",
+	Long: `Replays the corpus through the harness in a coverage build and writes a
+Go coverprofile and an HTML report. Functions in the harness package that
+are never reached by the corpus are listed.
+
+By default, the corpus in the workdir is replayed if the workdir exists.
+Otherwise, the committed corpus is replayed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target, commit, err := targetAndCommit(confFile, args[0])
+		if err != nil {
+			return err
+		}
+		corpus, err := coverCorpus(target.Corpus, lib.TempWorkdir(target.Name, commit))
+		if err != nil {
+			return err
+		}
+		tmp, err := ioutil.TempDir("", "fuzzinator-cover")
+		if err != nil {
+			return xerrors.Errorf("unable to create temp dir: %w", err)
+		}
+		defer os.RemoveAll(tmp)
+		if corpus, err = localCorpus(corpus, tmp); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(coverOut, 0755); err != nil {
+			return xerrors.Errorf("unable to create output dir: %w", err)
+		}
+		profile := filepath.Join(coverOut, target.Name+".coverprofile")
+		html := filepath.Join(coverOut, target.Name+".cover.html")
+		log.Printf("Replaying corpus %s", corpus)
+		err = lib.RunCoverage(lib.CoverConfig{
+			Harness:   target.Harness,
+			Corpus:    corpus,
+			Profile:   profile,
+			CoverPkgs: coverPkgs,
+		})
+		if err != nil {
+			return err
+		}
+		if err := lib.CoverHTML(profile, html, ""); err != nil {
+			return err
+		}
+		log.Printf("Wrote coverprofile %s and HTML report %s", profile, html)
+		funcs, err := lib.FuncCoverage(profile, target.Harness, "")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("coverage: %s\n", coverSummary(funcs))
+		var unreached []lib.FuncCover
+		for _, f := range funcs {
+			if !f.Reached() && f.Package == target.Harness.Package {
+				unreached = append(unreached, f)
+			}
+		}
+		if len(unreached) == 0 {
+			fmt.Printf("All functions in %s are reached\n", target.Harness.Package)
+			return nil
+		}
+		fmt.Printf("Functions in %s that are never reached:\n", target.Harness.Package)
+		for _, f := range unreached {
+			fmt.Printf("  %s\n", f)
+		}
+		return nil
+	},
+}
+
+func init() {
+	coverCmd.Flags().StringVar(&coverFrom, "from", "",
+		"corpus to replay: 'workdir' or 'corpus' (default: workdir if it exists)")
+	coverCmd.Flags().StringVar(&coverOut, "out", ".",
+		"directory the coverprofile and HTML report are written to")
+	coverCmd.PersistentFlags().StringSliceVar(&coverPkgs, "coverpkg", nil,
+		"packages to record coverage for (default: the harness package)")
+	coverCmd.AddCommand(coverDiffCmd)
+}
+
+// coverCorpus returns the corpus directory to replay according to the --from
+// flag.
+func coverCorpus(committed, workdir string) (string, error) {
+	switch coverFrom {
+	case coverFromCorpus:
+		return committed, nil
+	case coverFromWorkdir:
+		if _, err := os.Stat(lib.CorpusDir(workdir)); err != nil {
+			return "", xerrors.Errorf("workdir corpus not available: %w", err)
+		}
+		return lib.CorpusDir(workdir), nil
+	case "":
+		if _, err := os.Stat(lib.CorpusDir(workdir)); err == nil {
+			return lib.CorpusDir(workdir), nil
+		}
+		return committed, nil
+	default:
+		return "", xerrors.Errorf("invalid --from %q, must be %q or %q",
+			coverFrom, coverFromWorkdir, coverFromCorpus)
+	}
+}
+
+var coverDiffCmd = &cobra.Command{
+	Use:   "diff <target> <refA> <refB>",
+	Short: "compare the per-function coverage of the corpus at two commits",
+	Long: `Replays the committed corpus at both commits through the harness of the
+respective commit and reports the functions that gained and lost coverage.
+The commits are checked out to temporary directories, the worktree is not
+modified.`,
+	Args: cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target, err := loadTarget(confFile, args[0])
+		if err != nil {
+			return err
+		}
+		before, err := refCoverage(target, args[1])
+		if err != nil {
+			return err
+		}
+		after, err := refCoverage(target, args[2])
+		if err != nil {
+			return err
+		}
+		var gained, lost []lib.CoverChange
+		for _, c := range lib.DiffCoverage(before, after) {
+			if c.Delta() > 0 {
+				gained = append(gained, c)
+			} else {
+				lost = append(lost, c)
+			}
+		}
+		fmt.Printf("%s: %s\n", args[1], coverSummary(before))
+		fmt.Printf("%s: %s\n", args[2], coverSummary(after))
+		if len(gained)+len(lost) == 0 {
+			fmt.Println("No change in per-function coverage")
+			return nil
+		}
+		for _, section := range []struct {
+			title   string
+			changes []lib.CoverChange
+		}{{"Gained coverage", gained}, {"Lost coverage", lost}} {
+			if len(section.changes) == 0 {
+				continue
+			}
+			fmt.Printf("%s:\n", section.title)
+			for _, c := range section.changes {
+				fmt.Printf("  %+d %s\n", c.Delta(), c)
+			}
+		}
+		return nil
+	},
+}
+
+// refCoverage returns the per-function coverage of the committed corpus at
+// the git revision. The revision is checked out to a temporary directory.
+func refCoverage(target conf.Target, rev string) ([]lib.FuncCover, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, xerrors.Errorf("unable to get working directory: %w", err)
+	}
+	root, err := lib.RepoRoot(wd)
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := ioutil.TempDir("", "fuzzinator-cover-diff")
+	if err != nil {
+		return nil, xerrors.Errorf("unable to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	checkout := filepath.Join(tmp, "src")
+	commit, err := lib.CheckoutCommit(root, rev, checkout)
+	if err != nil {
+		return nil, err
+	}
+	// The config is relative to the working directory, which is mapped into
+	// the checkout.
+	dir, err := rebasePath(wd, root, checkout)
+	if err != nil {
+		return nil, err
+	}
+	corpus, err := checkoutCorpus(target.Corpus, root, checkout, filepath.Join(tmp, "fetched"))
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Replaying corpus of %s (%s)", rev, shortCommit(commit))
+	profile := filepath.Join(tmp, "cover.out")
+	err = lib.RunCoverage(lib.CoverConfig{
+		Harness:   target.Harness,
+		Corpus:    corpus,
+		Profile:   profile,
+		CoverPkgs: coverPkgs,
+		Dir:       dir,
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("unable to cover %s: %w", rev, err)
+	}
+	return lib.FuncCoverage(profile, target.Harness, dir)
+}
+
+// rebasePath maps the path inside the repository root to the same path inside
+// the checkout. Paths outside the repository are returned as absolute paths.
+func rebasePath(path, root, checkout string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", xerrors.Errorf("unable to resolve %q: %w", path, err)
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return abs, nil
+	}
+	return filepath.Join(checkout, rel), nil
+}
+
+// localCorpus returns the local directory of the corpus. A corpus that is
+// not stored in a local directory is fetched to the corpus dir of tmp.
+func localCorpus(corpus, tmp string) (string, error) {
+	if dir, ok := lib.LocalStoragePath(corpus); ok {
+		return dir, nil
+	}
+	log.Printf("Fetching corpus %s", corpus)
+	if err := lib.SetupCorpus(corpus, tmp); err != nil {
+		return "", xerrors.Errorf("unable to fetch corpus: %w", err)
+	}
+	return lib.CorpusDir(tmp), nil
+}
+
+// checkoutCorpus returns the corpus directory in the checkout. A corpus that
+// is not stored in a local directory is not versioned with the repository,
+// and is fetched to tmp instead.
+func checkoutCorpus(corpus, root, checkout, tmp string) (string, error) {
+	dir, ok := lib.LocalStoragePath(corpus)
+	if !ok {
+		return localCorpus(corpus, tmp)
+	}
+	return rebasePath(dir, root, checkout)
+}
+
+func coverSummary(funcs []lib.FuncCover) string {
+	var covered, total, reached int
+	for _, f := range funcs {
+		covered += f.Covered
+		total += f.Statements
+		if f.Reached() {
+			reached++
+		}
+	}
+	if total == 0 {
+		return "no statements covered"
+	}
+	return fmt.Sprintf("%.1f%% of statements (%d/%d), %d/%d functions reached",
+		100*float64(covered)/float64(total), covered, total, reached, len(funcs))
+}
@@ -26,14 +26,19 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"path/filepath"
+	"time"
 
 	"github.com/spf13/cobra"
 	"golang.org/x/xerrors"
 
+	"github.com/oncilla/fuzzinator/conf"
 	"github.com/oncilla/fuzzinator/lib"
 )
 
+// crashersPollInterval is the interval in which the crashers directory is
+// polled for new crashers while fuzzing.
+const crashersPollInterval = 2 * time.Second
+
 const msgTmplFmt = `
 Add crashers:
   - target: "%s"
@@ -45,46 +50,202 @@ Add crashers:
 var crashersCmd = &cobra.Command{
 	Use:   "crashers",
 	Short: "copy the crashers to the corpus and commit them",
-	Args:  cobra.ExactArgs(1),
+	Long: `crashers exports the crashers of the workdir that were not exported
+before. Crashers with an ignored signature or that match a suppression are
+skipped. The command exits with a non-zero status if the workdir contains
+crashers that are neither ignored nor suppressed, including the ones exported
+while fuzzing.`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		target, commit, err := targetAndCommit(confFile, args[0])
 		if err != nil {
 			return err
 		}
-		crashers := crashersOut(target.Corpus, commit, target.Crashers)
-		if err := copyCrashers(target.Name, crashers, commit, terminate); err != nil {
+		lock, err := lockWorkdir(target, commit)
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+		exporter, err := newCrasherExporter(target, commit)
+		if err != nil {
+			return err
+		}
+		log.Printf("Copying new crashers to %q", exporter.out)
+		summary, err := exporter.export()
+		if err != nil {
 			return err
 		}
-		added, err := lib.AddCrashers(crashers, target.Name, commit)
+		logExportSummary(summary, exporter.root)
+		out, local := lib.LocalStoragePath(exporter.out)
+		if !local {
+			if summary.Exported() == 0 {
+				log.Println("No new crashers found")
+			}
+			return unsuppressedErr(summary)
+		}
+		// Crashers might have been exported while fuzzing already.
+		if _, err := os.Stat(out); summary.Exported() == 0 && os.IsNotExist(err) {
+			log.Println("No new crashers found")
+			return unsuppressedErr(summary)
+		}
+		added, err := lib.AddCrashers(out, target.Name, commit)
 		if err != nil {
 			return err
 		}
 		if !added {
 			log.Println("No new crashers added")
-			return nil
+			return unsuppressedErr(summary)
 		}
 		msg := fmt.Sprintf(msgTmplFmt, target.Name, target.Harness.Package,
 			target.Harness.Function, commit)
 		log.Println("Please commit added crashers:")
 		fmt.Printf("\ngit commit -m '%s'\n", msg)
-		return nil
+		return unsuppressedErr(summary)
 	},
 }
 
-func copyCrashers(name, crashers, commit string, stop <-chan struct{}) error {
-	if err := os.MkdirAll(crashers, 0755); err != nil {
-		return xerrors.Errorf("unable to create crashers dir: %w", err)
+func init() {
+	addLockFlags(crashersCmd)
+}
+
+// crasherExporter exports the crashers from the workdir of a target.
+type crasherExporter struct {
+	workdir string
+	commit  string
+	// root is the storage location that contains the crashers of all
+	// commits.
+	root string
+	// out is the location the crashers are exported to.
+	out          string
+	ignored      map[string]bool
+	suppressions []conf.Suppression
+}
+
+func newCrasherExporter(target conf.Target, commit string) (*crasherExporter, error) {
+	root := crashersRoot(target.Corpus, target.Crashers)
+	storage, err := lib.OpenStorage(root)
+	if err != nil {
+		return nil, err
+	}
+	ignored, err := lib.ReadStorageIgnoreList(storage)
+	storage.Close()
+	if err != nil {
+		return nil, err
+	}
+	var suppressions []conf.Suppression
+	if target.Suppressions != "" {
+		if suppressions, err = conf.LoadSuppressions(target.Suppressions); err != nil {
+			return nil, err
+		}
+	}
+	return &crasherExporter{
+		workdir:      lib.TempWorkdir(target.Name, commit),
+		commit:       commit,
+		root:         root,
+		out:          lib.StorageLocation(root, commit),
+		ignored:      ignored,
+		suppressions: suppressions,
+	}, nil
+}
+
+// export copies the crashers that were not exported before, skipping the
+// ignored and suppressed ones.
+func (e *crasherExporter) export() (lib.ExportSummary, error) {
+	storage, err := lib.OpenStorage(e.root)
+	if err != nil {
+		return lib.ExportSummary{}, err
+	}
+	out := lib.SubStorage(storage, e.commit)
+	summary, err := lib.ExportCrashers(e.workdir, out, e.ignored, e.suppressions)
+	if err != nil {
+		out.Close()
+		return summary, err
+	}
+	if err := out.Close(); err != nil {
+		return summary, xerrors.Errorf("unable to write crashers: %w", err)
+	}
+	return summary, nil
+}
+
+// watch exports new crashers periodically until stop is closed, and logs a
+// triaged one-line summary for every crasher. The callback is called for
+// every exported crasher with the location of the exported input. The
+// returned channel is closed after the final export.
+func (e *crasherExporter) watch(stop <-chan struct{},
+	onExport func(crasher lib.Crasher, path string)) <-chan struct{} {
+
+	reported := make(map[string]bool)
+	report := func(crashers []lib.Crasher, triage string, exported bool) {
+		for _, c := range crashers {
+			if reported[c.Name] {
+				continue
+			}
+			reported[c.Name] = true
+			log.Printf("Crasher %s (%s signature %s): %s", c.Name, triage, c.Signature, c.Title)
+			if exported {
+				onExport(c, lib.StorageLocation(e.out, c.Name))
+			}
+		}
 	}
-	log.Printf("Copying crashers to %q", crashers)
-	if err := lib.CopyCrashers(lib.TempWorkdir(name, commit), crashers); err != nil {
-		return err
+	poll := func() {
+		summary, err := e.export()
+		if err != nil {
+			log.Printf("Unable to export crashers: %s", err)
+			return
+		}
+		report(summary.New, "new", true)
+		report(summary.Known, "known", true)
+		report(summary.Ignored, "ignored", false)
+		report(summary.Suppressed, "suppressed", false)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(crashersPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				poll()
+			case <-stop:
+				poll()
+				return
+			}
+		}
+	}()
+	return done
+}
+
+func logExportSummary(summary lib.ExportSummary, root string) {
+	log.Printf("Crashers: %d new, %d with known signature, %d ignored, %d suppressed, "+
+		"%d exported previously", len(summary.New), len(summary.Known),
+		len(summary.Ignored), len(summary.Suppressed), summary.Previous)
+	for _, crasher := range summary.New {
+		log.Printf("  new %s %s: %s", crasher.Signature, crasher.Name, crasher.Title)
+	}
+	for _, crasher := range summary.Known {
+		log.Printf("  known %s %s: %s", crasher.Signature, crasher.Name, crasher.Title)
+	}
+	if len(summary.New) > 0 {
+		log.Printf("Add signatures to %s to ignore them in the future",
+			lib.StorageLocation(root, lib.IgnoreListName))
+	}
+}
+
+// unsuppressedErr returns an error if the workdir contains crashers that are
+// neither ignored nor suppressed.
+func unsuppressedErr(summary lib.ExportSummary) error {
+	if n := summary.Unsuppressed(); n > 0 {
+		return xerrors.Errorf("found %d crasher(s) that are neither ignored nor suppressed", n)
 	}
 	return nil
 }
 
-func crashersOut(corpus, commit, crashers string) string {
+// crashersRoot returns the storage location that contains the crashers of
+// all commits and the ignore list.
+func crashersRoot(corpus, crashers string) string {
 	if crashers != "" {
-		return filepath.Join(crashers, commit)
+		return crashers
 	}
-	return filepath.Join(filepath.Dir(corpus), "crashers", commit)
+	return lib.DefaultCrashersLocation(corpus)
 }
@@ -23,12 +23,96 @@
 package cmd
 
 import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"golang.org/x/xerrors"
 
+	"github.com/oncilla/fuzzinator/conf"
 	"github.com/oncilla/fuzzinator/lib"
 )
 
+// optionFlags holds the command line overrides of the go-fuzz options.
+type optionFlags struct {
+	opts      conf.Options
+	sonar     bool
+	extraArgs []string
+}
+
+func (f *optionFlags) register(flags *pflag.FlagSet) {
+	flags.IntVar(&f.opts.Procs, "procs", 0, "number of parallel fuzzing processes")
+	flags.DurationVar(&f.opts.Timeout, "timeout", 0, "timeout for a single input")
+	flags.DurationVar(&f.opts.Minimize, "minimize", 0, "time limit for input minimization")
+	flags.BoolVar(&f.opts.DumpCover, "dumpcover", false, "dump coverage profile into workdir")
+	flags.BoolVar(&f.sonar, "sonar", true, "use sonar hints")
+	flags.IntVarP(&f.opts.Verbose, "verbose", "v", 0, "go-fuzz verbosity level")
+	flags.StringVar(&f.opts.Dict, "dict", "", "dictionary file in AFL/libFuzzer format")
+	flags.StringSliceVar(&f.extraArgs, "extra-args", nil,
+		"additional go-fuzz arguments, replaces the configured extra_args")
+}
+
+// apply overrides the target options with the flags set on the command line.
+func (f *optionFlags) apply(flags *pflag.FlagSet, target *conf.Target) error {
+	opts := &target.Options
+	if flags.Changed("procs") {
+		opts.Procs = f.opts.Procs
+	}
+	if flags.Changed("timeout") {
+		opts.Timeout = f.opts.Timeout
+	}
+	if flags.Changed("minimize") {
+		opts.Minimize = f.opts.Minimize
+	}
+	if flags.Changed("dumpcover") {
+		opts.DumpCover = f.opts.DumpCover
+	}
+	if flags.Changed("sonar") {
+		sonar := f.sonar
+		opts.Sonar = &sonar
+	}
+	if flags.Changed("verbose") {
+		opts.Verbose = f.opts.Verbose
+	}
+	if flags.Changed("dict") {
+		opts.Dict = f.opts.Dict
+	}
+	if flags.Changed("extra-args") {
+		target.ExtraArgs = f.extraArgs
+	}
+	if err := opts.Validate(); err != nil {
+		return xerrors.Errorf("invalid options: %w", err)
+	}
+	if err := conf.ValidateExtraArgs(target.ExtraArgs); err != nil {
+		return xerrors.Errorf("invalid extra args: %w", err)
+	}
+	return nil
+}
+
+// runFlags configure the fuzzing run.
+type runFlags struct {
+	watchCrashers bool
+	metricsAddr   string
+	// coordinator is the address go-fuzz coordinates workers on. It is set by
+	// the coordinator command.
+	coordinator          string
+	corpusServer         string
+	corpusSyncInterval   time.Duration
+	corpusReloadInterval time.Duration
+}
+
+var (
+	fuzzFlags optionFlags
+	runOpts   runFlags
+)
+
 var fuzzCmd = &cobra.Command{
 	Use:   "fuzz",
 	Short: "fuzz the target without setting up the workdirectory",
@@ -38,15 +122,200 @@ var fuzzCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		return fuzz(target.Name, commit, terminate)
+		if err := fuzzFlags.apply(cmd.Flags(), &target); err != nil {
+			return err
+		}
+		lock, err := lockWorkdir(target, commit)
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+		return fuzz(target, commit, runOpts, terminate)
 	},
 }
 
-func fuzz(targetName, commit string, stop <-chan struct{}) error {
-	workdir := lib.TempWorkdir(targetName, commit)
+func init() {
+	fuzzFlags.register(fuzzCmd.Flags())
+	addLockFlags(fuzzCmd)
+	addRunFlags(fuzzCmd)
+}
+
+func addRunFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&runOpts.watchCrashers, "watch-crashers", true,
+		"copy new crashers to the crashers directory while fuzzing")
+	cmd.Flags().StringVar(&runOpts.metricsAddr, "metrics-addr", "",
+		"serve prometheus metrics on the address while fuzzing, e.g., localhost:9090")
+	cmd.Flags().StringVar(&runOpts.corpusServer, "corpus-server", "",
+		"share the corpus and crashers with other machines through the corpus server "+
+			"at the address")
+	cmd.Flags().DurationVar(&runOpts.corpusSyncInterval, "corpus-sync-interval", time.Minute,
+		"interval in which the corpus is synchronized with the corpus server")
+	cmd.Flags().DurationVar(&runOpts.corpusReloadInterval, "corpus-reload-interval",
+		10*time.Minute, "minimum interval in which go-fuzz is restarted to load the corpus "+
+			"entries pulled from the corpus server")
+}
+
+// fuzz runs go-fuzz until stop is closed, or until the plateau action of the
+// target stops it. go-fuzz is restarted according to the watchdog config of
+// the target. Depending on the run flags, new crashers are exported, metrics
+// are served, and the corpus is shared through a corpus server while
+// fuzzing. The hooks of the target are run on new crashers, on coverage
+// plateaus and when fuzzing finishes.
+func fuzz(target conf.Target, commit string, opts runFlags, stop <-chan struct{}) error {
+	workdir := lib.TempWorkdir(target.Name, commit)
 	bin := lib.BinaryPath(workdir)
-	if err := lib.RunBinary(bin, workdir, stop); err != nil {
+	if err := lib.TouchWorkdir(workdir); err != nil {
+		return err
+	}
+	// halt is closed when fuzzing should stop, either because stop was closed
+	// or because of the plateau action.
+	halt := make(chan struct{})
+	var haltOnce sync.Once
+	stopFuzzing := func() { haltOnce.Do(func() { close(halt) }) }
+	go func() {
+		select {
+		case <-stop:
+			stopFuzzing()
+		case <-halt:
+		}
+	}()
+	hooks := newHookRunner(target, commit, workdir)
+	var done <-chan struct{}
+	if opts.watchCrashers {
+		exporter, err := newCrasherExporter(target, commit)
+		if err != nil {
+			return err
+		}
+		log.Printf("Watching for crashers, copying them to %q", exporter.out)
+		done = exporter.watch(halt, func(crasher lib.Crasher, path string) {
+			hooks.fire(target.Hooks.OnCrash, lib.HookEvent{
+				Event:       lib.EventCrash,
+				Crasher:     &crasher,
+				CrasherPath: path,
+			})
+		})
+	} else if len(target.Hooks.OnCrash) > 0 {
+		log.Println("Crashers are not watched, on_crash hooks will not run")
+	}
+	var synced <-chan struct{}
+	pulled := make(chan struct{}, 1)
+	if opts.corpusServer != "" {
+		if opts.corpusSyncInterval <= 0 {
+			return xerrors.Errorf("corpus sync interval must be positive: %s",
+				opts.corpusSyncInterval)
+		}
+		client := &lib.CorpusClient{Addr: opts.corpusServer, Target: target.Name}
+		log.Printf("Sharing the corpus through the corpus server at %s", opts.corpusServer)
+		// Entries pulled before go-fuzz starts are loaded without a restart.
+		syncCorpusOnce(client, workdir)
+		synced = syncCorpus(client, workdir, opts.corpusSyncInterval, pulled, halt)
+	}
+	metrics := lib.NewMetricsCollector(target.Name, commit, workdir)
+	if opts.metricsAddr != "" {
+		shutdown, err := serveMetrics(opts.metricsAddr, metrics)
+		if err != nil {
+			return err
+		}
+		defer shutdown()
+	}
+	plateau := lib.PlateauDetector{
+		Window:       target.Plateau.EffectiveWindow(),
+		CorpusWindow: target.Plateau.CorpusWindow,
+	}
+	plateaus := make(chan struct{}, 1)
+	// go-fuzz reports the status on both output streams concurrently.
+	var statusMtx sync.Mutex
+	onStatus := func(status lib.Status, counters lib.RunCounters) {
+		statusMtx.Lock()
+		defer statusMtx.Unlock()
+		hooks.setStatus(status)
+		metrics.Update(status, counters)
+		if err := lib.AppendStatus(workdir, status); err != nil {
+			log.Printf("Unable to record status: %s", err)
+		}
+		if plateau.Observe(status) {
+			log.Printf("Coverage plateau: %s", plateau.Reason(status.Time))
+			hooks.fire(target.Hooks.OnPlateau, lib.HookEvent{Event: lib.EventPlateau})
+			select {
+			case plateaus <- struct{}{}:
+			default:
+			}
+		}
+	}
+	args := []string{"-bin", bin, "-workdir", workdir}
+	if opts.coordinator != "" {
+		// The workers bring their own fuzzing binary.
+		args = []string{"-workdir", workdir, "-coordinator", opts.coordinator}
+	}
+	sup := &supervisor{
+		target:   target,
+		args:     append(args, target.FuzzArgs()...),
+		onStatus: onStatus,
+		plateaus: plateaus,
+		halt:     halt,
+		stop:     stopFuzzing,
+
+		reloadInterval: opts.corpusReloadInterval,
+	}
+	// Restarting the coordinator would disconnect the workers. It reports no
+	// executions while no workers are connected, so stalls are not detected
+	// either.
+	if opts.coordinator == "" {
+		sup.reloads = pulled
+	} else if target.Watchdog.Stall > 0 {
+		log.Println("Stall detection is disabled in coordinator mode")
+		sup.target.Watchdog.Stall = 0
+	}
+	err := sup.run()
+	stopFuzzing()
+	if done != nil {
+		<-done
+	}
+	if synced != nil {
+		<-synced
+	}
+	hooks.fire(target.Hooks.OnFinish, lib.HookEvent{Event: lib.EventFinish})
+	hooks.wait()
+	if err != nil {
 		return xerrors.Errorf("error while fuzzing: %w", err)
 	}
 	return nil
 }
+
+// serveMetrics serves the prometheus metrics on the address. The returned
+// function shuts the server down.
+func serveMetrics(addr string, metrics prometheus.Collector) (func(), error) {
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(metrics); err != nil {
+		return nil, xerrors.Errorf("unable to register metrics: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	listenAddr, shutdown, err := startServer(addr, mux)
+	if err != nil {
+		return nil, xerrors.Errorf("unable to serve metrics: %w", err)
+	}
+	log.Printf("Serving metrics on http://%s/metrics", listenAddr)
+	return shutdown, nil
+}
+
+// startServer serves the handler on the address in the background. It returns
+// the address the server listens on, and a function that shuts the server
+// down.
+func startServer(addr string, handler http.Handler) (net.Addr, func(), error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("unable to listen: %w", err)
+	}
+	srv := &http.Server{Handler: handler}
+	go func() {
+		if err := srv.Serve(listener); err != http.ErrServerClosed {
+			log.Printf("Error serving on %s: %s", listener.Addr(), err)
+		}
+	}()
+	return listener.Addr(), func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}, nil
+}
@@ -0,0 +1,81 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cmd
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/oncilla/fuzzinator/conf"
+	"github.com/oncilla/fuzzinator/lib"
+)
+
+// hookRunner runs the hooks of a target asynchronously. The last go-fuzz
+// status is attached to every event.
+type hookRunner struct {
+	target  string
+	commit  string
+	workdir string
+
+	wg     sync.WaitGroup
+	mtx    sync.Mutex
+	status *lib.Status
+}
+
+func newHookRunner(target conf.Target, commit, workdir string) *hookRunner {
+	return &hookRunner{target: target.Name, commit: commit, workdir: workdir}
+}
+
+// setStatus sets the last go-fuzz status.
+func (r *hookRunner) setStatus(status lib.Status) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.status = &status
+}
+
+// fire runs the hooks for the event. Failures are logged.
+func (r *hookRunner) fire(hooks []conf.Hook, event lib.HookEvent) {
+	if len(hooks) == 0 {
+		return
+	}
+	r.mtx.Lock()
+	event.Status = r.status
+	r.mtx.Unlock()
+	event.Target, event.Commit, event.Workdir = r.target, r.commit, r.workdir
+	event.Time = time.Now()
+	for _, hook := range hooks {
+		r.wg.Add(1)
+		go func(hook conf.Hook) {
+			defer r.wg.Done()
+			if err := lib.RunHook(hook, event); err != nil {
+				log.Printf("Error running %s hook: %s", event.Event, err)
+			}
+		}(hook)
+	}
+}
+
+// wait waits for all running hooks to finish.
+func (r *hookRunner) wait() {
+	r.wg.Wait()
+}
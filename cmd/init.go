@@ -0,0 +1,171 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+
+	"github.com/oncilla/fuzzinator/conf"
+	"github.com/oncilla/fuzzinator/lib"
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init [packages]",
+	Short: "discover fuzzing entry points and add them to the config file",
+	Long: `init scans the packages (default ./...) for go-fuzz entry points
+func Fuzz*(data []byte) int and adds a target for each of them to the config
+file. Existing targets are never modified. The corpus directory of every new
+target is created.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		patterns := args
+		if len(patterns) == 0 {
+			patterns = []string{"./..."}
+		}
+		funcs, err := lib.DiscoverFuzzFuncs(patterns...)
+		if err != nil {
+			return err
+		}
+		f, err := loadOrCreateConf(confFile)
+		if err != nil {
+			return err
+		}
+		targets := newTargets(f.Conf, funcs)
+		for _, target := range targets {
+			log.Printf("Adding target %q: %s.%s", target.Name, target.Harness.Package,
+				target.Harness.Function)
+		}
+		if len(targets) == 0 {
+			log.Println("No new targets found")
+			return nil
+		}
+		if err := f.AddTargets(targets...); err != nil {
+			return err
+		}
+		raw, err := f.Marshal()
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(confFile, raw, 0644); err != nil {
+			return xerrors.Errorf("unable to write config file: %w", err)
+		}
+		log.Printf("Added %d target(s) to %s", len(targets), confFile)
+		// The corpus directories are only created once the targets are part
+		// of the config file.
+		for _, target := range targets {
+			if err := os.MkdirAll(target.Corpus, 0755); err != nil {
+				return xerrors.Errorf("unable to create corpus dir: %w", err)
+			}
+		}
+		return nil
+	},
+}
+
+// loadOrCreateConf strictly parses the config file. If it does not exist, an
+// empty config file is returned.
+func loadOrCreateConf(file string) (*conf.File, error) {
+	raw, err := ioutil.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, xerrors.Errorf("unable to read config file at %s: %w", file, err)
+	}
+	f, problems := conf.Parse(file, raw)
+	if len(problems) > 0 {
+		return nil, xerrors.Errorf("invalid config file at %s:\n%w", file, problems)
+	}
+	return f, nil
+}
+
+// newTargets creates a target for every discovered go-fuzz entry point that is
+// not yet part of the config.
+func newTargets(cfg conf.Conf, funcs []lib.FuzzFunc) []conf.Target {
+	type harness struct{ pkg, function string }
+	known := make(map[harness]bool)
+	names := make(map[string]bool)
+	for name, target := range cfg.Targets {
+		known[harness{target.Harness.Package, target.Harness.Function}] = true
+		names[name] = true
+	}
+	var targets []conf.Target
+	for _, fn := range funcs {
+		if fn.Native {
+			log.Printf("Skipping %s.%s: native go fuzzing is not supported by go-fuzz",
+				fn.Package, fn.Function)
+			continue
+		}
+		if known[harness{fn.Package, fn.Function}] {
+			continue
+		}
+		name := targetName(fn, names)
+		names[name] = true
+		targets = append(targets, conf.Target{
+			Name:     name,
+			Corpus:   relPath(fn.Dir, "fuzzdata", fn.Function, "corpus"),
+			Crashers: relPath(fn.Dir, "fuzzdata", fn.Function, "crashers"),
+			Harness: conf.Harness{
+				Function: fn.Function,
+				Package:  fn.Package,
+			},
+		})
+	}
+	return targets
+}
+
+// targetName returns a target name for the entry point that is not taken yet.
+func targetName(fn lib.FuzzFunc, taken map[string]bool) string {
+	candidates := []string{fn.Function, fmt.Sprintf("%s.%s", fn.Name, fn.Function)}
+	for _, name := range candidates {
+		if !taken[name] {
+			return name
+		}
+	}
+	for i := 2; ; i++ {
+		if name := fmt.Sprintf("%s.%s_%d", fn.Name, fn.Function, i); !taken[name] {
+			return name
+		}
+	}
+}
+
+// relPath joins the elements to the directory and returns the path relative
+// to the working directory, if possible.
+func relPath(dir string, elem ...string) string {
+	path := filepath.Join(append([]string{dir}, elem...)...)
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(wd, path)
+	if err != nil {
+		return path
+	}
+	if strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return "." + string(filepath.Separator) + rel
+}
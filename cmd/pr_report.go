@@ -0,0 +1,216 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+
+	"github.com/oncilla/fuzzinator/conf"
+	"github.com/oncilla/fuzzinator/lib"
+)
+
+var prBase string
+
+var prReportCmd = &cobra.Command{
+	Use:   "pr-report [targets...]",
+	Short: "report changed lines that are not exercised by the fuzz corpora",
+	Long: `Determines the lines that changed between the merge base with --base and
+HEAD, replays the committed corpora of the targets against HEAD, and reports
+the changed lines in packages reachable from the harnesses that are never
+exercised by any fuzz input.
+
+By default, all go targets in the config file are replayed. HEAD is checked
+out to a temporary directory, uncommitted changes are not considered.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		names := args
+		if len(names) == 0 {
+			cfg, err := conf.Load(confFile)
+			if err != nil {
+				return err
+			}
+			for _, name := range cfg.Targets.Names() {
+				if cfg.Targets[name].IsGo() {
+					names = append(names, name)
+				}
+			}
+		}
+		var targets []conf.Target
+		for _, name := range names {
+			target, err := loadTarget(confFile, name)
+			if err != nil {
+				return err
+			}
+			targets = append(targets, target)
+		}
+		return prReport(targets, prBase)
+	},
+}
+
+func init() {
+	prReportCmd.Flags().StringVar(&prBase, "base", "master",
+		"base revision the changes are determined against")
+}
+
+// prReport prints the changed lines between base and HEAD that are not
+// exercised by the corpus of any of the targets.
+func prReport(targets []conf.Target, base string) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return xerrors.Errorf("unable to get working directory: %w", err)
+	}
+	root, err := lib.RepoRoot(wd)
+	if err != nil {
+		return err
+	}
+	changed, err := lib.ChangedLines(root, base, "HEAD")
+	if err != nil {
+		return err
+	}
+	if len(changed) == 0 {
+		fmt.Printf("No changes between %s and HEAD\n", base)
+		return nil
+	}
+	tmp, err := ioutil.TempDir("", "fuzzinator-pr-report")
+	if err != nil {
+		return xerrors.Errorf("unable to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	checkout := filepath.Join(tmp, "src")
+	commit, err := lib.CheckoutCommit(root, "HEAD", checkout)
+	if err != nil {
+		return err
+	}
+	dir, err := rebasePath(wd, root, checkout)
+	if err != nil {
+		return err
+	}
+	// covered contains the executable lines of all reachable packages, and
+	// whether any corpus exercises them.
+	covered := make(map[string]map[int]bool)
+	for _, target := range targets {
+		pkgs, err := lib.ReachablePackages(target.Harness, dir, checkout)
+		if err != nil {
+			return xerrors.Errorf("target %q: %w", target.Name, err)
+		}
+		corpus, err := checkoutCorpus(target.Corpus, root, checkout,
+			filepath.Join(tmp, "fetched", target.Name))
+		if err != nil {
+			return err
+		}
+		log.Printf("Replaying corpus of %s at %s (%d reachable packages)",
+			target.Name, shortCommit(commit), len(pkgs))
+		profile := filepath.Join(tmp, target.Name+".coverprofile")
+		err = lib.RunCoverage(lib.CoverConfig{
+			Harness:   target.Harness,
+			Corpus:    corpus,
+			Profile:   profile,
+			CoverPkgs: pkgs,
+			Dir:       dir,
+		})
+		if err != nil {
+			return xerrors.Errorf("target %q: %w", target.Name, err)
+		}
+		lines, err := lib.LineCoverage(profile, target.Harness, dir)
+		if err != nil {
+			return xerrors.Errorf("target %q: %w", target.Name, err)
+		}
+		for file, fileLines := range lines {
+			if covered[file] == nil {
+				covered[file] = make(map[int]bool)
+			}
+			for line, ok := range fileLines {
+				covered[file][line] = covered[file][line] || ok
+			}
+		}
+	}
+
+	var files []string
+	for file := range changed {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	var executable, exercised int
+	var report []string
+	for _, file := range files {
+		fileLines, ok := covered[filepath.Join(checkout, filepath.FromSlash(file))]
+		if !ok {
+			continue
+		}
+		var missed []int
+		for _, line := range changed[file] {
+			hit, ok := fileLines[line]
+			if !ok {
+				// Not a statement, e.g., a comment or a declaration.
+				continue
+			}
+			executable++
+			if hit {
+				exercised++
+			} else {
+				missed = append(missed, line)
+			}
+		}
+		if len(missed) > 0 {
+			report = append(report, fmt.Sprintf("  %s:%s", file, lineRanges(missed)))
+		}
+	}
+	if executable == 0 {
+		fmt.Println("No changed lines in packages reachable from the harnesses")
+		return nil
+	}
+	fmt.Printf("%d/%d changed lines in reachable packages are exercised by the corpora\n",
+		exercised, executable)
+	if len(report) > 0 {
+		fmt.Println("Changed lines never exercised by any fuzz input:")
+		fmt.Println(strings.Join(report, "\n"))
+	}
+	return nil
+}
+
+// lineRanges formats the sorted line numbers as comma separated ranges, e.g.,
+// "3-5,9".
+func lineRanges(lines []int) string {
+	var ranges []string
+	for i := 0; i < len(lines); {
+		j := i
+		for j+1 < len(lines) && lines[j+1] == lines[j]+1 {
+			j++
+		}
+		if i == j {
+			ranges = append(ranges, fmt.Sprint(lines[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", lines[i], lines[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(ranges, ",")
+}
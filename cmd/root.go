@@ -31,30 +31,45 @@ import (
 
 	"github.com/spf13/cobra"
 	"golang.org/x/xerrors"
-	"gopkg.in/yaml.v2"
 
 	"github.com/oncilla/fuzzinator/conf"
 	"github.com/oncilla/fuzzinator/lib"
 )
 
 var (
-	confFile  string
-	terminate <-chan struct{}
+	confFile      string
+	buildCacheDir string
+	workdirRoot   string
+	waitLock      bool
+	rootFlags     optionFlags
+	terminate     <-chan struct{}
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "fuzzinator",
 	Short: "fuzzinator streamlines go fuzzing",
 	Args:  cobra.ExactArgs(1),
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		lib.SetWorkdirRoot(resolveWorkdirRoot(confFile, workdirRoot))
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		target, commit, err := targetAndCommit(confFile, args[0])
 		if err != nil {
 			return err
 		}
-		if err := setup(target, commit, terminate); err != nil {
+		if err := rootFlags.apply(cmd.Flags(), &target); err != nil {
 			return err
 		}
-		if err := fuzz(target.Name, commit, terminate); err != nil {
+		lock, err := lockWorkdir(target, commit)
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+		applySetupFlags(cmd, &target)
+		if err := setup(target, commit, freshWorkdir, terminate); err != nil {
+			return err
+		}
+		if err := fuzz(target, commit, runOpts, terminate); err != nil {
 			return err
 		}
 		return nil
@@ -66,9 +81,28 @@ func init() {
 
 	rootCmd.PersistentFlags().StringVarP(&confFile, "conf", "c", "fuzzinator.yml",
 		"defines the config file path (default fuzzinator.yml)")
+	rootCmd.PersistentFlags().StringVar(&buildCacheDir, "build-cache", defaultBuildCacheDir(),
+		"defines the shared build cache directory, empty disables the cache "+
+			"(env FUZZINATOR_BUILD_CACHE)")
+	rootCmd.PersistentFlags().StringVar(&workdirRoot, "workdir-root", "",
+		"defines the directory that contains the workdirs, overrides workdir_root "+
+			"in the config file (env FUZZINATOR_WORKDIR_ROOT)")
+	rootFlags.register(rootCmd.Flags())
+	addSetupFlags(rootCmd)
+	addLockFlags(rootCmd)
+	addRunFlags(rootCmd)
 	rootCmd.AddCommand(setupCmd)
 	rootCmd.AddCommand(fuzzCmd)
 	rootCmd.AddCommand(crashersCmd)
+	rootCmd.AddCommand(validateCmd)
+	rootCmd.AddCommand(initCmd)
+	rootCmd.AddCommand(workdirCmd)
+	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(coverCmd)
+	rootCmd.AddCommand(prReportCmd)
+	rootCmd.AddCommand(coordinatorCmd)
+	rootCmd.AddCommand(workerCmd)
+	rootCmd.AddCommand(corpusServerCmd)
 }
 
 // Execute executes the comands.
@@ -81,21 +115,56 @@ func Execute() {
 	}
 }
 
-func targetAndCommit(confFile, targetName string) (conf.Target, string, error) {
-	var cfg conf.Conf
-	raw, err := ioutil.ReadFile(confFile)
-	if err != nil {
-		return conf.Target{}, "", xerrors.Errorf("unable to read config file at %s: %w",
-			confFile, err)
+func defaultBuildCacheDir() string {
+	if dir, ok := os.LookupEnv("FUZZINATOR_BUILD_CACHE"); ok {
+		return dir
 	}
-	if err := yaml.Unmarshal(raw, &cfg); err != nil {
-		return conf.Target{}, "", xerrors.Errorf("unable to parse config file at %s: %w",
-			confFile, err)
+	return lib.DefaultBuildCacheDir()
+}
+
+// resolveWorkdirRoot returns the workdir root. The flag takes precedence over
+// the environment, which takes precedence over the config file.
+func resolveWorkdirRoot(confFile, flag string) string {
+	if flag != "" {
+		return flag
 	}
-	target, ok := cfg.Targets[targetName]
-	if !ok {
-		return conf.Target{}, "", xerrors.Errorf("target not in config file at %s: %w",
-			confFile, err)
+	if dir := os.Getenv("FUZZINATOR_WORKDIR_ROOT"); dir != "" {
+		return dir
+	}
+	// Problems in the config file are reported by the command itself.
+	if raw, err := ioutil.ReadFile(confFile); err == nil {
+		if f, _ := conf.Parse(confFile, raw); f.Conf.WorkdirRoot != "" {
+			return f.Conf.WorkdirRoot
+		}
+	}
+	return lib.DefaultWorkdirRoot()
+}
+
+func addLockFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&waitLock, "wait", false,
+		"wait for the workdir to be released if it is in use by another process")
+}
+
+// lockWorkdir locks the workdir of the target, such that no other fuzzinator
+// process can use it concurrently. With --wait, it blocks until the workdir is
+// released.
+func lockWorkdir(target conf.Target, commit string) (*lib.WorkdirLock, error) {
+	workdir := lib.TempWorkdir(target.Name, commit)
+	if !waitLock {
+		return lib.LockWorkdir(workdir)
+	}
+	lock, err := lib.LockWorkdir(workdir)
+	if !xerrors.Is(err, lib.ErrWorkdirLocked) {
+		return lock, err
+	}
+	log.Printf("Waiting for lock: %s", err)
+	return lib.WaitLockWorkdir(workdir, terminate)
+}
+
+func targetAndCommit(confFile, targetName string) (conf.Target, string, error) {
+	target, err := loadTarget(confFile, targetName)
+	if err != nil {
+		return conf.Target{}, "", err
 	}
 	dir, err := lib.PkgDir(target.Harness.Package)
 	if err != nil {
@@ -109,6 +178,27 @@ func targetAndCommit(confFile, targetName string) (conf.Target, string, error) {
 	return target, commit, nil
 }
 
+// loadTarget loads the target from the config file and checks that the go
+// toolchain satisfies its version requirement.
+func loadTarget(confFile, targetName string) (conf.Target, error) {
+	cfg, err := conf.Load(confFile)
+	if err != nil {
+		return conf.Target{}, err
+	}
+	target, ok := cfg.Targets[targetName]
+	if !ok {
+		return conf.Target{}, xerrors.Errorf("target %q not in config file at %s",
+			targetName, confFile)
+	}
+	if err := target.CheckLanguage(); err != nil {
+		return conf.Target{}, xerrors.Errorf("target %q: %w", targetName, err)
+	}
+	if err := lib.CheckGoVersion(target.Version); err != nil {
+		return conf.Target{}, xerrors.Errorf("target %q: %w", targetName, err)
+	}
+	return target, nil
+}
+
 func handleSigTerm() <-chan struct{} {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt)
@@ -0,0 +1,390 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/hex"
+	"fmt"
+	"html"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+
+	"github.com/oncilla/fuzzinator/conf"
+	"github.com/oncilla/fuzzinator/lib"
+)
+
+var serveAddr string
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "serve a web dashboard of the workdirs",
+	Long: `Serve a web dashboard of the workdirs in the workdir root. It shows live
+stats of running targets, coverage over time, and the crashers with their
+stack traces and inputs. Everything is read from the workdirs, the dashboard
+does not need to run alongside the fuzzing processes.
+
+If the config file exists, the dashboard links the crashers exported to the
+crashers directories of the targets.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		d := &dashboard{targets: conf.TargetMap{}}
+		if raw, err := ioutil.ReadFile(confFile); err == nil {
+			f, _ := conf.Parse(confFile, raw)
+			if f.Conf.Targets != nil {
+				d.targets = f.Conf.Targets
+			}
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/", d.index)
+		mux.HandleFunc("/workdir/", d.workdir)
+		log.Printf("Serving dashboard of %s on http://%s", lib.WorkdirRoot(), serveAddr)
+		if err := http.ListenAndServe(serveAddr, mux); err != nil {
+			return xerrors.Errorf("unable to serve dashboard: %w", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "localhost:8080",
+		"defines the address the dashboard is served on")
+}
+
+var crasherName = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// dashboard serves the web dashboard.
+type dashboard struct {
+	targets conf.TargetMap
+}
+
+// workdirView is a workdir as shown in the dashboard.
+type workdirView struct {
+	lib.WorkdirInfo
+	Name    string
+	Running bool
+	// Status is the last go-fuzz status, if any.
+	Status *lib.Status
+}
+
+// crasherView is a crasher as shown in the dashboard.
+type crasherView struct {
+	lib.Crasher
+	// Exported is the file URL of the exported crasher, if any.
+	Exported template.URL
+	Output   string
+	Quoted   string
+	Hex      string
+}
+
+func (d *dashboard) index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	infos, err := lib.ListWorkdirs()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	views := make([]workdirView, 0, len(infos))
+	for _, info := range infos {
+		view, err := newWorkdirView(info)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		views = append(views, view)
+	}
+	d.render(w, "index", map[string]interface{}{
+		"Root":     lib.WorkdirRoot(),
+		"Workdirs": views,
+	})
+}
+
+// workdir serves /workdir/<name> and /workdir/<name>/<crasher>.
+func (d *dashboard) workdir(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/workdir/"), "/")
+	name := parts[0]
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") ||
+		len(parts) > 2 || (len(parts) == 2 && !crasherName.MatchString(parts[1])) {
+
+		http.NotFound(w, r)
+		return
+	}
+	path := filepath.Join(lib.WorkdirRoot(), name)
+	if _, err := os.Stat(path); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	info, err := lib.InspectWorkdir(path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	view, err := newWorkdirView(info)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(parts) == 2 {
+		d.crasher(w, r, view, parts[1])
+		return
+	}
+	history, err := lib.ReadStatusHistory(path, chartPoints)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	crashers, err := lib.ListCrashers(path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	views := make([]crasherView, 0, len(crashers))
+	for _, crasher := range crashers {
+		views = append(views, crasherView{Crasher: crasher, Exported: d.exported(view, crasher)})
+	}
+	d.render(w, "workdir", map[string]interface{}{
+		"Workdir":  view,
+		"Crashers": views,
+		"Charts": []template.HTML{
+			svgChart("cover", history, func(s lib.Status) float64 { return float64(s.Cover) }),
+			svgChart("corpus", history, func(s lib.Status) float64 { return float64(s.Corpus) }),
+			svgChart("execs/sec", history, func(s lib.Status) float64 { return s.ExecsPerSec }),
+		},
+	})
+}
+
+func (d *dashboard) crasher(w http.ResponseWriter, r *http.Request, view workdirView,
+	name string) {
+
+	crashers, err := lib.ListCrashers(view.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, crasher := range crashers {
+		if crasher.Name != name {
+			continue
+		}
+		read := func(suffix string) []byte {
+			raw, _ := ioutil.ReadFile(filepath.Join(lib.CrashersDir(view.Path), name+suffix))
+			return raw
+		}
+		d.render(w, "crasher", map[string]interface{}{
+			"Workdir": view,
+			"Crasher": crasherView{
+				Crasher:  crasher,
+				Exported: d.exported(view, crasher),
+				Output:   string(read(".output")),
+				Quoted:   string(read(".quoted")),
+				Hex:      hex.Dump(read("")),
+			},
+		})
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// exported returns the file URL of the exported crasher, if any. Crashers
+// exported to archives or remote storage are not linked.
+func (d *dashboard) exported(view workdirView, crasher lib.Crasher) template.URL {
+	target, ok := d.targets[view.Target]
+	if !ok {
+		return ""
+	}
+	dir, ok := lib.LocalStoragePath(crashersRoot(target.Corpus, target.Crashers))
+	if !ok {
+		return ""
+	}
+	path := filepath.Join(dir, view.Commit, crasher.Name)
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	return template.URL((&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String())
+}
+
+func (d *dashboard) render(w http.ResponseWriter, name string, data interface{}) {
+	if err := dashboardTmpl.ExecuteTemplate(w, name, data); err != nil {
+		log.Printf("Error rendering %s: %s", name, err)
+	}
+}
+
+func newWorkdirView(info lib.WorkdirInfo) (workdirView, error) {
+	view := workdirView{
+		WorkdirInfo: info,
+		Name:        filepath.Base(info.Path),
+		Running:     lib.WorkdirInUse(info.Path),
+	}
+	status, err := lib.LastStatus(info.Path)
+	if err != nil {
+		return view, err
+	}
+	view.Status = status
+	return view, nil
+}
+
+// chartPoints is the maximum number of points drawn by a chart.
+const chartPoints = 600
+
+// svgChart renders the values of the status history over time as an inline
+// SVG line chart.
+func svgChart(title string, history []lib.Status, value func(lib.Status) float64) template.HTML {
+	const width, height, maxPoints = 600, 120, chartPoints
+	var b strings.Builder
+	fmt.Fprintf(&b, `<figure><figcaption>%s</figcaption>`, html.EscapeString(title))
+	if len(history) < 2 {
+		b.WriteString(`<p>Not enough data.</p></figure>`)
+		return template.HTML(b.String())
+	}
+	step := (len(history) + maxPoints - 1) / maxPoints
+	var sampled []lib.Status
+	for i := 0; i < len(history); i += step {
+		sampled = append(sampled, history[i])
+	}
+	if last := history[len(history)-1]; sampled[len(sampled)-1] != last {
+		sampled = append(sampled, last)
+	}
+	start, end := sampled[0].Time, sampled[len(sampled)-1].Time
+	span := end.Sub(start)
+	var max float64
+	for _, s := range sampled {
+		if v := value(s); v > max {
+			max = v
+		}
+	}
+	fmt.Fprintf(&b, `<svg width="%d" height="%d" viewBox="0 0 %d %d">`,
+		width, height, width, height)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#f8f8f8"/><polyline fill="none" `+
+		`stroke="#2a6ebb" stroke-width="1.5" points="`, width, height)
+	for _, s := range sampled {
+		x := float64(width) / 2
+		if span > 0 {
+			x = float64(s.Time.Sub(start)) / float64(span) * width
+		}
+		y := float64(height)
+		if max > 0 {
+			y -= value(s) / max * (height - 10)
+		}
+		fmt.Fprintf(&b, "%.1f,%.1f ", x, y)
+	}
+	fmt.Fprintf(&b, `"/><text x="4" y="12" font-size="11">max %.0f</text>`, max)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="11" text-anchor="end">%s</text>`,
+		width-4, height-4, span.Round(time.Second))
+	b.WriteString(`</svg></figure>`)
+	return template.HTML(b.String())
+}
+
+var dashboardTmpl = template.Must(template.New("").Funcs(template.FuncMap{
+	"size":  byteSize,
+	"short": shortCommit,
+	"time": func(t time.Time) string {
+		return t.Format("2006-01-02 15:04:05")
+	},
+}).Parse(`
+{{define "head"}}<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>fuzzinator</title>
+{{if .}}<meta http-equiv="refresh" content="10">{{end}}
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { padding: 0.2em 0.8em; text-align: left; border-bottom: 1px solid #ddd; }
+pre { background: #f8f8f8; padding: 0.5em; overflow: auto; }
+.running { color: #080; font-weight: bold; }
+</style></head><body>
+<h1><a href="/">fuzzinator</a></h1>
+{{end}}
+
+{{define "status"}}{{with .}}
+<p>workers: {{.Workers}}, corpus: {{.Corpus}}, crashers: {{.Crashers}},
+execs: {{.Execs}} ({{printf "%.0f" .ExecsPerSec}}/sec), cover: {{.Cover}},
+uptime: {{.Uptime}}, reported: {{time .Time}}</p>
+{{end}}{{end}}
+
+{{define "index"}}{{template "head" true}}
+<p>Workdirs in {{.Root}}</p>
+<table>
+<tr><th>Target</th><th>Commit</th><th>State</th><th>Cover</th><th>Execs/sec</th>
+<th>Corpus</th><th>Crashers</th><th>Size</th><th>Last used</th></tr>
+{{range .Workdirs}}
+<tr><td><a href="/workdir/{{.Name}}">{{.Target}}</a></td><td>{{short .Commit}}</td>
+<td>{{if .Running}}<span class="running">running</span>{{else}}stopped{{end}}</td>
+<td>{{with .Status}}{{.Cover}}{{end}}</td>
+<td>{{with .Status}}{{printf "%.0f" .ExecsPerSec}}{{end}}</td>
+<td>{{.Corpus}}</td><td>{{.Crashers}}</td><td>{{size .Size}}</td>
+<td>{{time .LastUsed}}</td></tr>
+{{else}}
+<tr><td colspan="9">No workdirs found.</td></tr>
+{{end}}
+</table></body></html>
+{{end}}
+
+{{define "workdir"}}{{template "head" .Workdir.Running}}
+{{with .Workdir}}
+<h2>{{.Target}} @ {{.Commit}}
+{{if .Running}}<span class="running">running</span>{{end}}</h2>
+<p>{{.Path}}, {{size .Size}}, {{.Corpus}} corpus entries, last used {{time .LastUsed}}</p>
+{{template "status" .Status}}
+{{end}}
+{{range .Charts}}{{.}}{{end}}
+<h3>Crashers</h3>
+<table>
+<tr><th>Crasher</th><th>Signature</th><th>Panic</th><th>Exported</th></tr>
+{{range .Crashers}}
+<tr><td><a href="/workdir/{{$.Workdir.Name}}/{{.Name}}">{{short .Name}}</a></td>
+<td>{{.Signature}}</td><td>{{.Title}}</td>
+<td>{{with .Exported}}<a href="{{.}}">{{.}}</a>{{end}}</td></tr>
+{{else}}
+<tr><td colspan="4">No crashers found.</td></tr>
+{{end}}
+</table></body></html>
+{{end}}
+
+{{define "crasher"}}{{template "head" false}}
+<h2><a href="/workdir/{{.Workdir.Name}}">{{.Workdir.Target}} @ {{short .Workdir.Commit}}</a>:
+crasher {{.Crasher.Name}}</h2>
+<p>Signature {{.Crasher.Signature}}{{with .Crasher.Exported}}, exported to
+<a href="{{.}}">{{.}}</a>{{end}}</p>
+<h3>Stack</h3>
+<ol>{{range .Crasher.Stack}}<li>{{.}}</li>{{end}}</ol>
+<h3>Output</h3><pre>{{.Crasher.Output}}</pre>
+<h3>Input (quoted)</h3><pre>{{.Crasher.Quoted}}</pre>
+<h3>Input (hex)</h3><pre>{{.Crasher.Hex}}</pre>
+</body></html>
+{{end}}
+`))
@@ -24,6 +24,8 @@ package cmd
 
 import (
 	"log"
+	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 	"golang.org/x/xerrors"
@@ -32,6 +34,11 @@ import (
 	"github.com/oncilla/fuzzinator/lib"
 )
 
+var (
+	freshWorkdir bool
+	carryCorpus  bool
+)
+
 var setupCmd = &cobra.Command{
 	Use:   "setup",
 	Short: "setup the temporary workdir and build the fuzzing binary",
@@ -41,23 +48,138 @@ var setupCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		return setup(target, commit, terminate)
+		applySetupFlags(cmd, &target)
+		lock, err := lockWorkdir(target, commit)
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+		return setup(target, commit, freshWorkdir, terminate)
 	},
 }
 
-func setup(target conf.Target, commit string, stop <-chan struct{}) error {
+func init() {
+	addSetupFlags(setupCmd)
+	addLockFlags(setupCmd)
+}
+
+func addSetupFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&freshWorkdir, "fresh", false,
+		"remove the existing workdir and rebuild the fuzzing binary")
+	cmd.Flags().BoolVar(&carryCorpus, "carry-corpus", false,
+		"seed a new workdir with the corpus of the closest ancestor commit's workdir")
+}
+
+func applySetupFlags(cmd *cobra.Command, target *conf.Target) {
+	if cmd.Flags().Changed("carry-corpus") {
+		target.CarryCorpus = carryCorpus
+	}
+}
+
+// setup sets up the workdir. If the workdir was already set up for the same
+// commit and config, its corpus is resumed instead of being overwritten, and
+// the fuzzing binary is only rebuilt if it is outdated.
+func setup(target conf.Target, commit string, fresh bool, stop <-chan struct{}) error {
+	if fresh {
+		workdir := lib.TempWorkdir(target.Name, commit)
+		log.Println("Removing existing workdir", workdir)
+		if err := os.RemoveAll(workdir); err != nil {
+			return xerrors.Errorf("unable to remove workdir: %w", err)
+		}
+	}
 	workdir, err := lib.SetupTempWorkdir(target.Name, commit)
 	log.Println("Setting up temporary workdir", workdir)
 	if err != nil {
 		return xerrors.Errorf("unable to setup temp dir: %w", err)
 	}
-	log.Println("Copying corpus to temporary workdir")
-	if err := lib.SetupCorpus(target.Corpus, workdir); err != nil {
-		return xerrors.Errorf("unable to setup corpus: %w", err)
+	meta, compatible := lib.CompatibleWorkdir(workdir, target, commit)
+	if compatible {
+		log.Println("Resuming from the corpus in the existing workdir")
+	} else {
+		log.Println("Copying corpus to temporary workdir")
+		if err := lib.SetupCorpus(target.Corpus, workdir); err != nil {
+			return xerrors.Errorf("unable to setup corpus: %w", err)
+		}
+		if target.CarryCorpus {
+			if err := carryOverCorpus(target, commit, workdir); err != nil {
+				return err
+			}
+		}
+		meta = lib.WorkdirMeta{
+			Target:     target.Name,
+			Commit:     commit,
+			ConfigHash: lib.ConfigHash(target),
+			Created:    time.Now(),
+		}
+	}
+	cfg, err := buildBinary(target, workdir, fresh, stop)
+	if err != nil {
+		return err
+	}
+	meta.BuildKey = cfg.Key()
+	meta.LastUsed = time.Now()
+	return lib.WriteWorkdirMeta(workdir, meta)
+}
+
+// carryOverCorpus seeds the workdir with the corpus of the workdir of the
+// closest ancestor commit.
+func carryOverCorpus(target conf.Target, commit, workdir string) error {
+	dir, err := lib.PkgDir(target.Harness.Package)
+	if err != nil {
+		return xerrors.Errorf("error resolving package %q: %w", target.Harness.Package, err)
+	}
+	previous, err := lib.PreviousWorkdir(dir, target.Name, commit)
+	if err != nil {
+		return xerrors.Errorf("unable to find previous workdir: %w", err)
 	}
-	log.Println("Building fuzzing binary", lib.BinaryPath(workdir))
-	if _, err := lib.BuildBinary(target, workdir, stop); err != nil {
-		return xerrors.Errorf("unable to build fuzzing binary: %w", err)
+	if previous == "" {
+		log.Println("No workdir of an ancestor commit found to carry the corpus from")
+		return nil
 	}
+	added, err := lib.SeedCorpus(lib.CorpusDir(previous), workdir)
+	if err != nil {
+		return xerrors.Errorf("unable to carry over corpus: %w", err)
+	}
+	log.Printf("Carried over %d corpus entries from %s", added, previous)
 	return nil
 }
+
+// buildBinary restores the fuzzing binary from the build cache, or builds it
+// and adds it to the cache. If the binary in the workdir is up to date, it is
+// kept. A fresh build bypasses the cache lookup. The resolved build
+// configuration is returned.
+func buildBinary(target conf.Target, workdir string, fresh bool,
+	stop <-chan struct{}) (lib.BuildConfig, error) {
+
+	cfg := lib.NewBuildConfig(target)
+	if err := cfg.Resolve(); err != nil {
+		return cfg, xerrors.Errorf("unable to resolve build config: %w", err)
+	}
+	if lib.BinaryUpToDate(workdir, cfg) {
+		log.Printf("Fuzzing binary %s is up to date", lib.BinaryPath(workdir))
+		return cfg, nil
+	}
+	cache := lib.BuildCache{Dir: buildCacheDir}
+	if cache.Dir != "" && !fresh {
+		hit, err := cache.Restore(cfg, workdir)
+		if err != nil {
+			return cfg, err
+		}
+		if hit {
+			log.Printf("Build cache hit for key %s, reusing fuzzing binary", cfg.Key())
+			return cfg, nil
+		}
+	}
+	log.Printf("Building fuzzing binary %s (build key %s)", lib.BinaryPath(workdir), cfg.Key())
+	bin, err := lib.BuildBinary(cfg, workdir, stop)
+	if err != nil {
+		return cfg, xerrors.Errorf("unable to build fuzzing binary: %w", err)
+	}
+	if cache.Dir == "" {
+		return cfg, nil
+	}
+	if err := cache.Store(cfg, bin); err != nil {
+		return cfg, xerrors.Errorf("unable to add fuzzing binary to build cache: %w", err)
+	}
+	return cfg, nil
+}
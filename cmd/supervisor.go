@@ -0,0 +1,185 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/oncilla/fuzzinator/conf"
+	"github.com/oncilla/fuzzinator/lib"
+)
+
+// watchdogInterval is the interval in which the watchdog checks for stalls.
+const watchdogInterval = time.Second
+
+// supervisor runs go-fuzz, takes the plateau actions, and restarts go-fuzz on
+// the same workdir when the watchdog detects a stall or go-fuzz exits
+// unexpectedly.
+type supervisor struct {
+	target conf.Target
+	// args are the go-fuzz arguments.
+	args []string
+	// onStatus is called for every go-fuzz status with the run counters.
+	onStatus func(lib.Status, lib.RunCounters)
+	// plateaus receives a value for every coverage plateau.
+	plateaus <-chan struct{}
+	// halt is closed when fuzzing should stop.
+	halt <-chan struct{}
+	// stop stops fuzzing, i.e., it closes halt.
+	stop func()
+
+	// reloads receives a value whenever corpus entries were pulled into the
+	// workdir. go-fuzz only reads the corpus on startup, so it is restarted
+	// to pick them up, at most once per reloadInterval.
+	reloads        <-chan struct{}
+	reloadInterval time.Duration
+
+	watchdog lib.Watchdog
+	niced    bool
+
+	// counters are cumulative over restarts. execsBase is the number of
+	// executions of the previous go-fuzz processes.
+	countersMtx sync.Mutex
+	counters    lib.RunCounters
+	execsBase   uint64
+}
+
+// reasonReload is the reason for restarts that load pulled corpus entries.
+const reasonReload = "reload"
+
+// run runs go-fuzz until halt is closed. An error is returned if go-fuzz
+// cannot be started, or if it needs to be restarted but is not allowed to.
+func (s *supervisor) run() error {
+	cfg := s.target.Watchdog
+	s.watchdog.Stall = cfg.Stall
+	onStatus := func(status lib.Status) {
+		s.watchdog.Observe(status)
+		s.countersMtx.Lock()
+		s.counters.Execs = s.execsBase + status.Execs
+		counters := s.counters
+		s.countersMtx.Unlock()
+		s.onStatus(status, counters)
+	}
+	restarts := 0
+	for {
+		s.watchdog.Reset(time.Now())
+		proc, err := lib.StartGoFuzz(s.args, onStatus)
+		if err != nil {
+			return err
+		}
+		if s.niced {
+			// The priority is not inherited from the previous process.
+			if err := proc.Nice(); err != nil {
+				log.Printf("Unable to lower priority: %s", err)
+			}
+		}
+		reason, restart := s.supervise(proc)
+		if err := proc.Stop(); err != nil {
+			return err
+		}
+		if reason == "" {
+			return nil
+		}
+		if reason == reasonReload {
+			// Reloads are planned and do not count as restarts.
+			log.Println("Restarting go-fuzz to load the corpus entries pulled from " +
+				"the corpus server")
+			s.restarted()
+			continue
+		}
+		log.Printf("go-fuzz %s", reason)
+		if !restart {
+			return xerrors.Errorf("go-fuzz %s", reason)
+		}
+		if restarts >= cfg.EffectiveMaxRestarts() {
+			return xerrors.Errorf("go-fuzz %s, giving up after %d restarts", reason, restarts)
+		}
+		restarts++
+		log.Printf("Restarting go-fuzz on the same workdir (restart %d/%d)", restarts,
+			cfg.EffectiveMaxRestarts())
+		s.restarted()
+	}
+}
+
+// restarted carries the counters of the stopped go-fuzz process over to the
+// next one.
+func (s *supervisor) restarted() {
+	s.countersMtx.Lock()
+	defer s.countersMtx.Unlock()
+	s.execsBase = s.counters.Execs
+	s.counters.Restarts++
+}
+
+// supervise waits until halt is closed, or until go-fuzz needs to be
+// restarted. In the latter case, the reason is returned, and whether the
+// watchdog config allows a restart. The plateau actions are taken in the
+// meantime.
+func (s *supervisor) supervise(proc *lib.FuzzProcess) (string, bool) {
+	ticker := time.NewTicker(watchdogInterval)
+	defer ticker.Stop()
+	started := time.Now()
+	var reload bool
+	for {
+		if reload && time.Since(started) >= s.reloadInterval {
+			return reasonReload, true
+		}
+		select {
+		case <-s.halt:
+			return "", false
+		case <-proc.Exited():
+			if err := proc.Err(); err != nil {
+				return fmt.Sprintf("exited unexpectedly: %s", err), s.target.Watchdog.RestartOnExit
+			}
+			return "exited unexpectedly", s.target.Watchdog.RestartOnExit
+		case <-ticker.C:
+			if reason, stalled := s.watchdog.Stalled(time.Now()); stalled {
+				return "stalled: " + reason, true
+			}
+		case <-s.plateaus:
+			s.plateau(proc)
+		case <-s.reloads:
+			reload = true
+		}
+	}
+}
+
+// plateau takes the plateau action of the target.
+func (s *supervisor) plateau(proc *lib.FuzzProcess) {
+	switch s.target.Plateau.EffectiveAction() {
+	case conf.PlateauStop:
+		log.Println("Stopping fuzzing, because coverage plateaued (plateau action 'stop')")
+		s.stop()
+	case conf.PlateauNice:
+		log.Printf("Lowering priority of go-fuzz to %d, because coverage plateaued "+
+			"(plateau action 'nice')", lib.NicePriority)
+		if err := proc.Nice(); err != nil {
+			log.Printf("Unable to lower priority: %s", err)
+		}
+		s.niced = true
+	}
+}
@@ -0,0 +1,144 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+
+	"github.com/oncilla/fuzzinator/conf"
+	"github.com/oncilla/fuzzinator/lib"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "strictly validate the config file and all its targets",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		raw, err := ioutil.ReadFile(confFile)
+		if err != nil {
+			return xerrors.Errorf("unable to read config file at %s: %w", confFile, err)
+		}
+		f, problems := conf.Parse(confFile, raw)
+		problems = append(problems, f.Check()...)
+		problems = append(problems, checkTargets(f)...)
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		for _, warning := range f.Warnings() {
+			fmt.Printf("%s (ignored)\n", warning)
+		}
+		if len(problems) > 0 {
+			return xerrors.Errorf("found %d problem(s) in %s", len(problems), confFile)
+		}
+		log.Printf("Config file %s is valid", confFile)
+		return nil
+	},
+}
+
+// checkTargets checks that the corpus exists and that the harness resolves
+// to a valid fuzzing entry point for every target.
+func checkTargets(f *conf.File) conf.Problems {
+	var problems conf.Problems
+	for _, name := range f.Conf.Targets.Names() {
+		target := f.Conf.Targets[name]
+		if !target.IsGo() {
+			continue
+		}
+		if err := lib.CheckGoVersion(target.Version); err != nil {
+			problems = append(problems, f.Problemf(name, "version",
+				"target %q: %s", name, err))
+		}
+		if target.Corpus != "" {
+			if err := checkCorpus(target.Corpus); err != nil {
+				problems = append(problems, f.Problemf(name, "corpus",
+					"target %q: %s", name, err))
+			}
+		}
+		if target.Crashers != "" {
+			if storage, err := lib.OpenStorage(target.Crashers); err != nil {
+				problems = append(problems, f.Problemf(name, "crashers",
+					"target %q: crashers not accessible: %s", name, err))
+			} else {
+				storage.Close()
+			}
+		}
+		if dict := target.Options.Dict; dict != "" {
+			if _, err := os.Stat(dict); err != nil {
+				problems = append(problems, f.Problemf(name, "options.dict",
+					"target %q: dictionary not accessible: %s", name, err))
+			}
+		}
+		if target.Suppressions != "" {
+			if _, err := conf.LoadSuppressions(target.Suppressions); err != nil {
+				problems = append(problems, f.Problemf(name, "suppressions",
+					"target %q: %s", name, err))
+			}
+		}
+		if target.Harness.Package == "" {
+			continue
+		}
+		if _, err := lib.PkgDir(target.Harness.Package); err != nil {
+			problems = append(problems, f.Problemf(name, "harness.package",
+				"target %q: %s", name, err))
+			continue
+		}
+		if target.Harness.Function == "" {
+			continue
+		}
+		if err := lib.CheckHarness(target.Harness); err != nil {
+			problems = append(problems, f.Problemf(name, "harness.function",
+				"target %q: %s", name, err))
+		}
+	}
+	return problems
+}
+
+// checkCorpus checks that the corpus location is accessible. Local corpora
+// must be directories.
+func checkCorpus(corpus string) error {
+	if dir, ok := lib.LocalStoragePath(corpus); ok {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return xerrors.Errorf("corpus not accessible: %w", err)
+		}
+		if !info.IsDir() {
+			return xerrors.Errorf("corpus %s is not a directory", corpus)
+		}
+		return nil
+	}
+	storage, err := lib.OpenStorage(corpus)
+	if err != nil {
+		return xerrors.Errorf("corpus not accessible: %w", err)
+	}
+	defer storage.Close()
+	if _, err := storage.List(); err != nil {
+		return xerrors.Errorf("corpus not accessible: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+
+	"github.com/oncilla/fuzzinator/lib"
+)
+
+var cleanFlags struct {
+	olderThan   time.Duration
+	target      string
+	unreachable bool
+	repo        string
+	all         bool
+	dryRun      bool
+}
+
+var workdirCmd = &cobra.Command{
+	Use:   "workdir",
+	Short: "manage the temporary workdirs",
+}
+
+var workdirListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list the temporary workdirs",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		infos, err := lib.ListWorkdirs()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TARGET\tCOMMIT\tSIZE\tCORPUS\tCRASHERS\tLAST USED")
+		for _, info := range infos {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\n", info.Target, shortCommit(info.Commit),
+				byteSize(info.Size), info.Corpus, info.Crashers,
+				info.LastUsed.Format("2006-01-02 15:04"))
+		}
+		return w.Flush()
+	},
+}
+
+var workdirInspectCmd = &cobra.Command{
+	Use:   "inspect <workdir>",
+	Short: "show the details of a temporary workdir",
+	Long: `Show the details of a temporary workdir. The workdir is either a path, or
+the name of a directory in the workdir root, e.g., <target>_<commit>.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		workdir := args[0]
+		if _, err := os.Stat(workdir); err != nil {
+			workdir = filepath.Join(lib.WorkdirRoot(), args[0])
+		}
+		if _, err := os.Stat(workdir); err != nil {
+			return xerrors.Errorf("workdir %q not found: %w", args[0], err)
+		}
+		info, err := lib.InspectWorkdir(workdir)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "Path:\t%s\n", info.Path)
+		fmt.Fprintf(w, "Target:\t%s\n", info.Target)
+		fmt.Fprintf(w, "Commit:\t%s\n", info.Commit)
+		fmt.Fprintf(w, "Size:\t%s\n", byteSize(info.Size))
+		fmt.Fprintf(w, "Corpus:\t%d entries\n", info.Corpus)
+		fmt.Fprintf(w, "Crashers:\t%d\n", info.Crashers)
+		fmt.Fprintf(w, "Last used:\t%s\n", info.LastUsed.Format(time.RFC3339))
+		if info.Meta != nil {
+			fmt.Fprintf(w, "Created:\t%s\n", info.Meta.Created.Format(time.RFC3339))
+			fmt.Fprintf(w, "Config hash:\t%s\n", info.Meta.ConfigHash)
+			fmt.Fprintf(w, "Build key:\t%s\n", info.Meta.BuildKey)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if cfg, err := lib.ReadBuildConfig(lib.BuildConfigPath(workdir)); err == nil {
+			raw, err := json.MarshalIndent(cfg, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Build config:\n%s\n", raw)
+		}
+		return nil
+	},
+}
+
+var workdirCleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "remove temporary workdirs",
+	Long: `Remove temporary workdirs. Only workdirs that match all of the given filters
+are removed. At least one filter, or --all, must be provided.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f := cleanFlags
+		if !f.all && f.olderThan == 0 && f.target == "" && !f.unreachable {
+			return xerrors.New("no filter specified, use --all to remove all workdirs")
+		}
+		infos, err := lib.ListWorkdirs()
+		if err != nil {
+			return err
+		}
+		var unreachable map[string]bool
+		if f.unreachable {
+			commits := make([]string, 0, len(infos))
+			for _, info := range infos {
+				commits = append(commits, info.Commit)
+			}
+			if unreachable, err = lib.UnreachableCommits(f.repo, commits); err != nil {
+				return err
+			}
+		}
+		for _, info := range infos {
+			switch {
+			case f.olderThan != 0 && time.Since(info.LastUsed) < f.olderThan:
+				continue
+			case f.target != "" && info.Target != f.target:
+				continue
+			case f.unreachable && !unreachable[info.Commit]:
+				continue
+			}
+			if f.dryRun {
+				fmt.Printf("Would remove %s (%s)\n", info.Path, byteSize(info.Size))
+				continue
+			}
+			if err := removeWorkdir(info); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	workdirCleanCmd.Flags().DurationVar(&cleanFlags.olderThan, "older-than", 0,
+		"remove workdirs that have not been used for the given duration")
+	workdirCleanCmd.Flags().StringVar(&cleanFlags.target, "target", "",
+		"remove workdirs of the given target")
+	workdirCleanCmd.Flags().BoolVar(&cleanFlags.unreachable, "unreachable", false,
+		"remove workdirs of commits in --repo that are no longer reachable from any branch")
+	workdirCleanCmd.Flags().StringVar(&cleanFlags.repo, "repo", ".",
+		"defines the git repository used by --unreachable")
+	workdirCleanCmd.Flags().BoolVar(&cleanFlags.all, "all", false,
+		"remove all workdirs")
+	workdirCleanCmd.Flags().BoolVarP(&cleanFlags.dryRun, "dry-run", "n", false,
+		"only print the workdirs that would be removed")
+	workdirCmd.AddCommand(workdirListCmd)
+	workdirCmd.AddCommand(workdirInspectCmd)
+	workdirCmd.AddCommand(workdirCleanCmd)
+}
+
+// removeWorkdir removes the workdir. Workdirs that are in use are skipped. The
+// lock file is kept, such that processes waiting for the lock and processes
+// that lock the workdir later agree on the same file.
+func removeWorkdir(info lib.WorkdirInfo) error {
+	lock, err := lib.LockWorkdir(info.Path)
+	if xerrors.Is(err, lib.ErrWorkdirLocked) {
+		log.Printf("Skipping %s, it is in use", info.Path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	log.Printf("Removing %s (%s)", info.Path, byteSize(info.Size))
+	if err := os.RemoveAll(info.Path); err != nil {
+		return xerrors.Errorf("unable to remove workdir: %w", err)
+	}
+	return nil
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
+
+// byteSize formats the size in human readable binary units.
+func byteSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
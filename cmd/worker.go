@@ -0,0 +1,151 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+
+	"github.com/oncilla/fuzzinator/lib"
+)
+
+var (
+	workerProcs           int
+	workerBuild           bool
+	workerSkipCommitCheck bool
+)
+
+var workerCmd = &cobra.Command{
+	Use:   "worker <addr>",
+	Short: "run a go-fuzz worker for the coordinator at the address",
+	Long: `Fetches the fuzzing binary from the coordinator started with
+'fuzzinator coordinator' and runs go-fuzz in worker mode.
+
+If the worker runs in a git repository, its HEAD must match the commit of
+the coordinator. With --build, the fuzzing binary is built from the local
+checkout instead of being downloaded, which requires the config file.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		info, err := lib.FetchCoordinatorInfo(args[0])
+		if err != nil {
+			return err
+		}
+		log.Printf("Coordinator fuzzes target %q at commit %s on %s", info.Target,
+			info.Commit, info.Coordinator)
+		dir, err := ioutil.TempDir("", "fuzzinator-worker")
+		if err != nil {
+			return xerrors.Errorf("unable to create worker dir: %w", err)
+		}
+		defer os.RemoveAll(dir)
+		if workerBuild {
+			err = buildWorkerBinary(info, dir)
+		} else {
+			err = fetchWorkerBinary(args[0], info, dir)
+		}
+		if err != nil {
+			return err
+		}
+		goFuzzArgs := append([]string{"-bin", lib.BinaryPath(dir), "-worker",
+			info.Coordinator}, info.Args...)
+		if workerProcs > 0 {
+			goFuzzArgs = append(goFuzzArgs, fmt.Sprintf("-procs=%d", workerProcs))
+		}
+		proc, err := lib.StartGoFuzz(goFuzzArgs, nil)
+		if err != nil {
+			return err
+		}
+		select {
+		case <-terminate:
+			return proc.Stop()
+		case <-proc.Exited():
+			if err := proc.Err(); err != nil {
+				return xerrors.Errorf("go-fuzz worker exited: %w", err)
+			}
+			return nil
+		}
+	},
+}
+
+func init() {
+	workerCmd.Flags().IntVar(&workerProcs, "procs", 0,
+		"number of parallel fuzzing processes (default: number of CPUs)")
+	workerCmd.Flags().BoolVar(&workerBuild, "build", false,
+		"build the fuzzing binary locally instead of downloading it")
+	workerCmd.Flags().BoolVar(&workerSkipCommitCheck, "skip-commit-check", false,
+		"do not verify that the local commit matches the coordinator")
+}
+
+// fetchWorkerBinary downloads the fuzzing binary from the coordinator. If the
+// working directory is a git repository, its HEAD must match the coordinator.
+func fetchWorkerBinary(addr string, info lib.CoordinatorInfo, dir string) error {
+	if commit, err := lib.CommitHash("."); err != nil {
+		log.Printf("Unable to verify commit: %s", err)
+	} else if err := checkWorkerCommit(commit, info); err != nil {
+		return err
+	}
+	log.Printf("Downloading fuzzing binary (sha256 %s)", info.BinarySHA256)
+	return lib.FetchBinary(addr, info, lib.BinaryPath(dir))
+}
+
+// buildWorkerBinary builds the fuzzing binary for the target of the
+// coordinator from the local checkout, which must be at the same commit.
+func buildWorkerBinary(info lib.CoordinatorInfo, dir string) error {
+	target, commit, err := targetAndCommit(confFile, info.Target)
+	if err != nil {
+		return err
+	}
+	if err := checkWorkerCommit(commit, info); err != nil {
+		return err
+	}
+	cfg := lib.NewBuildConfig(target)
+	if err := cfg.Resolve(); err != nil {
+		return xerrors.Errorf("unable to resolve build config: %w", err)
+	}
+	if cfg.Key() != info.BuildKey {
+		log.Printf("Build key %s differs from the coordinator's %s, "+
+			"e.g., because of a different go version", cfg.Key(), info.BuildKey)
+	}
+	log.Printf("Building fuzzing binary %s", lib.BinaryPath(dir))
+	if _, err := lib.BuildBinary(cfg, dir, terminate); err != nil {
+		return xerrors.Errorf("unable to build fuzzing binary: %w", err)
+	}
+	return nil
+}
+
+func checkWorkerCommit(commit string, info lib.CoordinatorInfo) error {
+	if commit == info.Commit {
+		return nil
+	}
+	if workerSkipCommitCheck {
+		log.Printf("Local commit %s does not match the coordinator commit %s, "+
+			"ignored because of --skip-commit-check", commit, info.Commit)
+		return nil
+	}
+	return xerrors.Errorf("local commit %s does not match the coordinator commit %s",
+		commit, info.Commit)
+}
@@ -0,0 +1,145 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package conf
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Build configures go-fuzz-build.
+type Build struct {
+	// Race enables the race detector.
+	Race bool `yaml:"race,omitempty"`
+	// Preserve lists import paths that are not instrumented.
+	Preserve []string `yaml:"preserve,omitempty"`
+	// X prints the commands run by go-fuzz-build.
+	X bool `yaml:"x,omitempty"`
+	// CPUProfile makes go-fuzz-build write a cpu profile to cpu.pprof.
+	CPUProfile bool `yaml:"cpuprofile,omitempty"`
+	// GCFlags are passed to the go compiler through GOFLAGS.
+	GCFlags string `yaml:"gcflags,omitempty"`
+	// LDFlags are passed to the go linker through GOFLAGS.
+	LDFlags string `yaml:"ldflags,omitempty"`
+	// Env contains additional environment variables, e.g., CGO_ENABLED.
+	Env map[string]string `yaml:"env,omitempty"`
+}
+
+// Validate checks that the build configuration can be passed to
+// go-fuzz-build.
+func (b Build) Validate() error {
+	for _, pkg := range b.Preserve {
+		if pkg == "" || strings.ContainsAny(pkg, ", ") {
+			return xerrors.Errorf("invalid import path in preserve: %q", pkg)
+		}
+	}
+	if _, err := quoteGoflag("-gcflags=" + b.GCFlags); err != nil {
+		return xerrors.Errorf("invalid gcflags: %w", err)
+	}
+	if _, err := quoteGoflag("-ldflags=" + b.LDFlags); err != nil {
+		return xerrors.Errorf("invalid ldflags: %w", err)
+	}
+	for key, value := range b.Env {
+		if !envName.MatchString(key) {
+			return xerrors.Errorf("invalid environment variable name: %q", key)
+		}
+		if key == "CGO_ENABLED" && value != "0" && value != "1" {
+			return xerrors.Errorf("CGO_ENABLED must be 0 or 1: %q", value)
+		}
+	}
+	return nil
+}
+
+// Args returns the go-fuzz-build command line arguments.
+func (b Build) Args() []string {
+	var args []string
+	if b.Race {
+		args = append(args, "-race")
+	}
+	if len(b.Preserve) > 0 {
+		args = append(args, "-preserve", strings.Join(b.Preserve, ","))
+	}
+	if b.X {
+		args = append(args, "-x")
+	}
+	if b.CPUProfile {
+		args = append(args, "-cpuprofile")
+	}
+	return args
+}
+
+// Environ returns the additional environment in the form KEY=VALUE, sorted by
+// key. The gcflags and ldflags are appended to GOFLAGS. Flags that contain
+// whitespace are quoted, which requires go 1.19 or later. The build
+// configuration must be valid.
+func (b Build) Environ() []string {
+	env := make(map[string]string, len(b.Env)+1)
+	for key, value := range b.Env {
+		env[key] = value
+	}
+	var goflags []string
+	// The configured GOFLAGS are kept verbatim, including their quoting.
+	if flags := strings.TrimSpace(env["GOFLAGS"]); flags != "" {
+		goflags = append(goflags, flags)
+	}
+	if b.GCFlags != "" {
+		flag, _ := quoteGoflag("-gcflags=" + b.GCFlags)
+		goflags = append(goflags, flag)
+	}
+	if b.LDFlags != "" {
+		flag, _ := quoteGoflag("-ldflags=" + b.LDFlags)
+		goflags = append(goflags, flag)
+	}
+	if len(goflags) > 0 {
+		env["GOFLAGS"] = strings.Join(goflags, " ")
+	}
+	environ := make([]string, 0, len(env))
+	for key, value := range env {
+		environ = append(environ, fmt.Sprintf("%s=%s", key, value))
+	}
+	sort.Strings(environ)
+	return environ
+}
+
+// quoteGoflag quotes the flag for GOFLAGS if it contains whitespace. GOFLAGS
+// supports single and double quotes without escaping, a flag that contains
+// whitespace and both kinds of quotes cannot be represented.
+func quoteGoflag(flag string) (string, error) {
+	switch {
+	case !strings.ContainsAny(flag, " \t\n\r"):
+		return flag, nil
+	case !strings.Contains(flag, "'"):
+		return "'" + flag + "'", nil
+	case !strings.Contains(flag, `"`):
+		return `"` + flag + `"`, nil
+	default:
+		return "", xerrors.Errorf("flag with whitespace must not contain both quote kinds: %q",
+			flag)
+	}
+}
@@ -22,13 +22,99 @@
 
 package conf
 
-import "golang.org/x/xerrors"
+import (
+	"sort"
+
+	"golang.org/x/xerrors"
+	"gopkg.in/yaml.v3"
+)
+
+// LanguageGo is the only language supported by fuzzinator.
+const LanguageGo = "go"
 
 // Conf configures the fuzzinator. It is designed to be compatible with
-// fuzzbuzz.io project yaml.
+// fuzzbuzz.io project yaml. Fields that only matter to fuzzbuzz.io are
+// modeled, such that project files can be read and written without loss.
 type Conf struct {
+	// Base is the docker base image used by fuzzbuzz.io. It is ignored by
+	// fuzzinator.
+	Base string `yaml:"base,omitempty"`
+	// Setup contains the setup commands run by fuzzbuzz.io. They are ignored
+	// by fuzzinator.
+	Setup []string `yaml:"setup,omitempty"`
+	// WorkdirRoot is the directory that contains the workdirs. If it is not
+	// set, the workdirs are created in the temp directory.
+	WorkdirRoot string `yaml:"workdir_root,omitempty"`
 	// Targets contains all fuzzing targets.
 	Targets TargetMap `yaml:"targets"`
+
+	// order is the order of the targets in the config file.
+	order []string
+}
+
+// UnmarshalYAML decodes the config and records the order of the targets, such
+// that it is kept when the config is marshalled again.
+func (c *Conf) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain Conf
+	// Type errors are returned unwrapped after recording the order, such that
+	// the decoder can report all of them.
+	err := unmarshal((*plain)(c))
+	if _, ok := err.(*yaml.TypeError); err != nil && !ok {
+		return err
+	}
+	// Problems of the raw document are already reported above. Targets
+	// without an order are sorted by name when marshalling.
+	var raw map[string]interface{}
+	c.order = nil
+	if unmarshal(&raw) != nil {
+		return err
+	}
+	targets, _ := raw["targets"].([]interface{})
+	for _, target := range targets {
+		fields, _ := target.(map[string]interface{})
+		if name, ok := fields["name"].(string); ok {
+			c.order = append(c.order, name)
+		}
+	}
+	return err
+}
+
+// MarshalYAML encodes the config with the targets in the order they were read
+// in. Targets that were added afterwards follow sorted by name.
+func (c Conf) MarshalYAML() (interface{}, error) {
+	type plain Conf
+	var node yaml.Node
+	if err := node.Encode(plain(c)); err != nil {
+		return nil, xerrors.Errorf("marshalling config: %w", err)
+	}
+	_, seq := mappingEntry(&node, "targets")
+	if seq == nil {
+		return &node, nil
+	}
+	targets := make([]Target, 0, len(c.Targets))
+	for _, name := range c.targetNames() {
+		targets = append(targets, c.Targets[name])
+	}
+	if err := seq.Encode(targets); err != nil {
+		return nil, xerrors.Errorf("marshalling targets: %w", err)
+	}
+	return &node, nil
+}
+
+// targetNames returns the target names in the order they were read in,
+// followed by the remaining names sorted.
+func (c Conf) targetNames() []string {
+	names := make([]string, 0, len(c.Targets))
+	seen := make(map[string]bool, len(c.Targets))
+	for _, list := range [][]string{c.order, c.Targets.Names()} {
+		for _, name := range list {
+			if _, ok := c.Targets[name]; ok && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
 }
 
 // TargetMap contains all targets and ensures no two targets can share the same
@@ -38,7 +124,10 @@ type TargetMap map[string]Target
 // UnmarshalYAML translates the targets list to a map
 func (m *TargetMap) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	var list []Target
-	if err := unmarshal(&list); err != nil {
+	// Type errors are returned unwrapped after processing the partially
+	// decoded list, such that the decoder can report all of them.
+	err := unmarshal(&list)
+	if _, ok := err.(*yaml.TypeError); err != nil && !ok {
 		return xerrors.Errorf("unmarshalling list: %w", err)
 	}
 	*m = make(TargetMap)
@@ -48,24 +137,93 @@ func (m *TargetMap) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		}
 		(*m)[target.Name] = target
 	}
-	return nil
+	return err
+}
+
+// MarshalYAML translates the target map to a list sorted by name. Conf keeps
+// the order of the config file, see Conf.MarshalYAML.
+func (m TargetMap) MarshalYAML() (interface{}, error) {
+	list := make([]Target, 0, len(m))
+	for _, name := range m.Names() {
+		list = append(list, m[name])
+	}
+	return list, nil
+}
+
+// Names returns the sorted target names.
+func (m TargetMap) Names() []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 // Target defines a single fuzzing target.
 type Target struct {
-	Name     string  `yaml:"name"`
-	Corpus   string  `yaml:"corpus"`
-	Crashers string  `yaml:"crashers"`
-	Harness  Harness `yaml:"harness"`
+	Name string `yaml:"name"`
+	// Language is the language of the target. Only go is supported. If it is
+	// not set, go is assumed.
+	Language string `yaml:"language,omitempty"`
+	// Version is the go version the target requires, e.g., "1.11".
+	Version string `yaml:"version,omitempty"`
+	// Setup contains the target specific setup commands run by fuzzbuzz.io.
+	// They are ignored by fuzzinator.
+	Setup []string `yaml:"setup,omitempty"`
+	// Corpus is the location of the corpus. It is a local directory, or a URL
+	// with one of the schemes "file", "tar", "zip" or "s3", e.g.,
+	// "tar://corpus.tar.gz" or "s3://bucket/prefix".
+	Corpus string `yaml:"corpus"`
+	// Crashers is the location the crashers are exported to, with the same
+	// schemes as the corpus. If it is not set, crashers are exported next to
+	// the corpus.
+	Crashers string `yaml:"crashers,omitempty"`
+	// Suppressions is the path to the suppression file, which lists the
+	// crashers that are accepted behavior, see LoadSuppressions.
+	Suppressions string `yaml:"suppressions,omitempty"`
+	// CarryCorpus seeds new workdirs with the evolved corpus of the workdir
+	// of the closest ancestor commit.
+	CarryCorpus bool    `yaml:"carry_corpus,omitempty"`
+	Harness     Harness `yaml:"harness"`
+	// Options configures the go-fuzz engine.
+	Options Options `yaml:"options,omitempty"`
+	// ExtraArgs are passed verbatim to go-fuzz. They serve as an escape hatch
+	// for flags that are not covered by the options.
+	ExtraArgs []string `yaml:"extra_args,omitempty"`
+	// Hooks are run on fuzzing events.
+	Hooks Hooks `yaml:"hooks,omitempty"`
+	// Plateau configures when coverage is considered to have stopped growing.
+	Plateau Plateau `yaml:"plateau,omitempty"`
+	// Watchdog restarts go-fuzz when it stalls or exits unexpectedly.
+	Watchdog Watchdog `yaml:"watchdog,omitempty"`
+}
+
+// IsGo indicates whether the target is a go target.
+func (t Target) IsGo() bool {
+	return t.Language == "" || t.Language == LanguageGo
+}
+
+// CheckLanguage checks that the target is a go target.
+func (t Target) CheckLanguage() error {
+	if !t.IsGo() {
+		return xerrors.Errorf("language %q is not supported, only %q", t.Language, LanguageGo)
+	}
+	return nil
 }
 
 // Harness defines the fuzzing harness.
 type Harness struct {
 	// BuildTags contains the optional build tags. The 'gofuzz' build tag will
 	// be set by fuzzinator itself.
-	BuildTags string `yaml:"build_tags"`
+	BuildTags string `yaml:"build_tags,omitempty"`
 	// Function specifies the entry point for fuzzing.
 	Function string `yaml:"function"`
 	// Package specifies the package of the entry point.
 	Package string `yaml:"package"`
+	// Build configures go-fuzz-build.
+	Build Build `yaml:"build,omitempty"`
+	// Checkout is the import path of the repository that fuzzbuzz.io checks
+	// out. It is ignored by fuzzinator, which uses the local repository.
+	Checkout string `yaml:"checkout,omitempty"`
 }
@@ -23,12 +23,14 @@
 package conf_test
 
 import (
+	"bytes"
 	"io/ioutil"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"gopkg.in/yaml.v2"
+	"gopkg.in/yaml.v3"
 
 	"github.com/oncilla/fuzzinator/conf"
 )
@@ -39,22 +41,232 @@ func TestCompatible(t *testing.T) {
 	var cfg conf.Conf
 	err = yaml.Unmarshal(raw, &cfg)
 	require.NoError(t, err)
+	assert.Equal(t, "ubuntu:16.04", cfg.Base)
 	yamlTarget := conf.Target{
-		Name:   "FromYAML",
-		Corpus: "./corpus",
+		Name:     "FromYAML",
+		Language: "go",
+		Version:  "1.11",
+		Corpus:   "./corpus",
 		Harness: conf.Harness{
 			Function: "FromYAML",
 			Package:  "github.com/fuzzbuzz/tutorial",
+			Checkout: "github.com/fuzzbuzz/tutorial",
 		},
 	}
 	assert.Equal(t, yamlTarget, cfg.Targets[yamlTarget.Name])
 	jsonTarget := conf.Target{
-		Name:   "FromJSON",
-		Corpus: "./corpus",
+		Name:     "FromJSON",
+		Language: "go",
+		Version:  "1.11",
+		Corpus:   "./corpus",
 		Harness: conf.Harness{
 			Function: "FromJSON",
 			Package:  "github.com/fuzzbuzz/tutorial",
+			Checkout: "github.com/fuzzbuzz/tutorial",
 		},
 	}
 	assert.Equal(t, jsonTarget, cfg.Targets[jsonTarget.Name])
 }
+
+func TestRoundTrip(t *testing.T) {
+	raw, err := ioutil.ReadFile("testdata/fuzzbuzz.yml")
+	require.NoError(t, err)
+
+	marshal := func(cfg conf.Conf) string {
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		require.NoError(t, enc.Encode(cfg))
+		require.NoError(t, enc.Close())
+		return buf.String()
+	}
+	t.Run("conf", func(t *testing.T) {
+		var cfg conf.Conf
+		require.NoError(t, yaml.Unmarshal(raw, &cfg))
+		// The targets are not sorted by name, the file order is kept.
+		assert.Equal(t, string(raw), marshal(cfg))
+	})
+	t.Run("file", func(t *testing.T) {
+		f, problems := conf.Parse("testdata/fuzzbuzz.yml", raw)
+		require.Empty(t, problems)
+		require.Empty(t, f.Check())
+		assert.Equal(t, string(raw), marshal(f.Conf))
+		out, err := f.Marshal()
+		require.NoError(t, err)
+		assert.Equal(t, string(raw), string(out))
+	})
+}
+
+func TestNonGoTarget(t *testing.T) {
+	raw := []byte("targets:\n  - name: c\n    language: c\n    corpus: ./corpus\n" +
+		"    harness:\n      function: LLVMFuzzerTestOneInput\n")
+	f, problems := conf.Parse("fuzzbuzz.yml", raw)
+	require.Empty(t, problems)
+	assert.Empty(t, f.Check())
+	warnings := f.Warnings()
+	require.Len(t, warnings, 1)
+	assert.Equal(t, 3, warnings[0].Pos.Line)
+	assert.Error(t, f.Conf.Targets["c"].CheckLanguage())
+}
+
+func TestParseStrict(t *testing.T) {
+	raw, err := ioutil.ReadFile("testdata/invalid.yml")
+	require.NoError(t, err)
+	f, problems := conf.Parse("invalid.yml", raw)
+	require.Len(t, problems, 1)
+	assert.Equal(t, 7, problems[0].Pos.Line)
+	assert.Contains(t, problems[0].Msg, "fucntion")
+
+	problems = f.Check()
+	require.Len(t, problems, 1)
+	assert.Equal(t, conf.Position{File: "invalid.yml", Line: 10, Column: 5}, problems[0].Pos)
+	assert.Contains(t, problems[0].Msg, "harness.function")
+}
+
+func TestLoad(t *testing.T) {
+	_, err := conf.Load("testdata/invalid.yml")
+	assert.Error(t, err)
+	cfg, err := conf.Load("../test/fuzzinator.yml")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fuzz"}, cfg.Targets.Names())
+}
+
+func TestAddTargets(t *testing.T) {
+	raw := []byte("# fuzzing targets\ntargets:\n  - name: a # first\n    corpus: ./a\n" +
+		"    harness:\n      function: FuzzA\n      package: example.com/a\n")
+	f, problems := conf.Parse("fuzzinator.yml", raw)
+	require.Empty(t, problems)
+	b := conf.Target{
+		Name:   "b",
+		Corpus: "./b",
+		Harness: conf.Harness{
+			Function: "FuzzB",
+			Package:  "example.com/b",
+		},
+	}
+	require.NoError(t, f.AddTargets(b))
+	assert.Error(t, f.AddTargets(b))
+	out, err := f.Marshal()
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "# fuzzing targets")
+	assert.Contains(t, string(out), "# first")
+
+	f, problems = conf.Parse("fuzzinator.yml", out)
+	require.Empty(t, problems)
+	assert.Equal(t, []string{"a", "b"}, f.Conf.Targets.Names())
+	assert.Equal(t, b, f.Conf.Targets["b"])
+}
+
+func TestOptions(t *testing.T) {
+	raw := []byte(`targets:
+  - name: fuzz
+    corpus: ./corpus
+    harness:
+      function: Fuzz
+      package: example.com/fuzz
+    options:
+      procs: 4
+      timeout: 1500ms
+      minimize: 2m
+      sonar: false
+      v: 1
+      dict: ./fuzz.dict
+    extra_args: ["-testoutput"]
+`)
+	f, problems := conf.Parse("fuzzinator.yml", raw)
+	require.Empty(t, problems)
+	require.Empty(t, f.Check())
+	expected := []string{"-procs=4", "-timeout=2", "-minimize=2m0s", "-sonar=false",
+		"-v=1", "-dict=./fuzz.dict", "-testoutput"}
+	assert.Equal(t, expected, f.Conf.Targets["fuzz"].FuzzArgs())
+
+	raw = bytes.Replace(raw, []byte("procs: 4"), []byte("procs: -1"), 1)
+	raw = bytes.Replace(raw, []byte(`"-testoutput"`), []byte(`"-workdir=/tmp"`), 1)
+	f, problems = conf.Parse("fuzzinator.yml", raw)
+	require.Empty(t, problems)
+	problems = f.Check()
+	require.Len(t, problems, 2)
+	assert.Equal(t, 7, problems[0].Pos.Line)
+	assert.Equal(t, 14, problems[1].Pos.Line)
+}
+
+func TestBuild(t *testing.T) {
+	build := conf.Build{
+		Race:     true,
+		Preserve: []string{"example.com/a", "example.com/b"},
+		GCFlags:  "all=-d=checkptr",
+		LDFlags:  "-s",
+		Env:      map[string]string{"CGO_ENABLED": "0", "GOFLAGS": "-mod=vendor"},
+	}
+	require.NoError(t, build.Validate())
+	assert.Equal(t, []string{"-race", "-preserve", "example.com/a,example.com/b"}, build.Args())
+	assert.Equal(t, []string{"CGO_ENABLED=0",
+		"GOFLAGS=-mod=vendor -gcflags=all=-d=checkptr -ldflags=-s"}, build.Environ())
+
+	build.LDFlags = "-X main.version=1.2 -s -w"
+	require.NoError(t, build.Validate())
+	assert.Equal(t, []string{"CGO_ENABLED=0", "GOFLAGS=-mod=vendor -gcflags=all=-d=checkptr " +
+		"'-ldflags=-X main.version=1.2 -s -w'"}, build.Environ())
+	build.LDFlags = `-X 'main.version=1.2'`
+	assert.Contains(t, build.Environ()[1], `"-ldflags=-X 'main.version=1.2'"`)
+	build.LDFlags = `-X 'main.name="x"'`
+	assert.Error(t, build.Validate())
+	build.LDFlags = ""
+	build.Env["CGO_ENABLED"] = "yes"
+	assert.Error(t, build.Validate())
+}
+
+func TestSuppressions(t *testing.T) {
+	suppressions, err := conf.LoadSuppressions("testdata/suppressions.yml")
+	require.NoError(t, err)
+	require.Len(t, suppressions, 2)
+	invariant, decode := suppressions[0], suppressions[1]
+	assert.Equal(t, "intentional panic on corrupted input", invariant.Reason)
+
+	assert.True(t, invariant.Matches("ba9876543210", "panic: invariant violated: x", nil))
+	assert.False(t, invariant.Matches("ba9876543210", "panic: index out of range", nil))
+
+	stack := []string{"github.com/oncilla/fuzzinator/test.decode", "main.main"}
+	assert.True(t, decode.Matches("0123456789ab", "panic: any", stack))
+	assert.False(t, decode.Matches("ba9876543210", "panic: any", stack))
+	assert.False(t, decode.Matches("0123456789ab", "panic: any", stack[1:]))
+
+	assert.Error(t, conf.Suppression{Reason: "no pattern"}.Validate())
+	assert.Error(t, conf.Suppression{Panic: "("}.Validate())
+	assert.Error(t, conf.Suppression{Stack: "("}.Validate())
+	invalid := conf.Suppression{Panic: "("}
+	assert.Error(t, invalid.Compile())
+	assert.False(t, invalid.Matches("0123456789ab", "(", nil))
+	assert.True(t, conf.Suppression{Panic: "any"}.Matches("0123456789ab", "panic: any", nil))
+}
+
+func TestHooks(t *testing.T) {
+	valid := conf.Hooks{
+		OnCrash:   []conf.Hook{{Command: "notify-send crash"}},
+		OnPlateau: []conf.Hook{{URL: "https://example.com/hook"}},
+	}
+	assert.NoError(t, valid.Validate())
+	invalid := []conf.Hooks{
+		{OnCrash: []conf.Hook{{}}},
+		{OnFinish: []conf.Hook{{Command: "true", URL: "http://localhost"}}},
+		{OnPlateau: []conf.Hook{{URL: "localhost:8080"}}},
+		{OnPlateau: []conf.Hook{{URL: "ftp://localhost"}}},
+	}
+	for _, hooks := range invalid {
+		assert.Error(t, hooks.Validate(), hooks)
+	}
+	assert.Equal(t, conf.DefaultPlateauWindow, conf.Plateau{}.EffectiveWindow())
+	assert.Error(t, conf.Plateau{Window: -time.Minute}.Validate())
+	assert.Error(t, conf.Plateau{CorpusWindow: -time.Minute}.Validate())
+	assert.Error(t, conf.Plateau{Action: "pause"}.Validate())
+	assert.NoError(t, conf.Plateau{Window: time.Hour, Action: conf.PlateauStop}.Validate())
+	assert.Equal(t, conf.PlateauContinue, conf.Plateau{}.EffectiveAction())
+}
+
+func TestWatchdog(t *testing.T) {
+	assert.NoError(t, conf.Watchdog{Stall: time.Minute, RestartOnExit: true}.Validate())
+	assert.Error(t, conf.Watchdog{Stall: -time.Minute}.Validate())
+	assert.Error(t, conf.Watchdog{MaxRestarts: -1}.Validate())
+	assert.Equal(t, conf.DefaultMaxRestarts, conf.Watchdog{}.EffectiveMaxRestarts())
+	assert.Equal(t, 5, conf.Watchdog{MaxRestarts: 5}.EffectiveMaxRestarts())
+}
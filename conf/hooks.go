@@ -0,0 +1,156 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package conf
+
+import (
+	"net/url"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// DefaultPlateauWindow is the default duration without coverage growth after
+// which fuzzing is considered to have plateaued.
+const DefaultPlateauWindow = 30 * time.Minute
+
+// Hooks are run on fuzzing events.
+type Hooks struct {
+	// OnCrash hooks run for every new crasher. Crashers are only detected
+	// while crashers are watched.
+	OnCrash []Hook `yaml:"on_crash,omitempty"`
+	// OnFinish hooks run when fuzzing stops.
+	OnFinish []Hook `yaml:"on_finish,omitempty"`
+	// OnPlateau hooks run when coverage stops growing, see Plateau.
+	OnPlateau []Hook `yaml:"on_plateau,omitempty"`
+}
+
+// Hook is either a shell command or an HTTP endpoint. Both receive a JSON
+// payload describing the event, on stdin and as POST body respectively.
+type Hook struct {
+	// Command is run with 'sh -c'. The event, target, commit and crasher are
+	// also set in the FUZZINATOR_* environment variables.
+	Command string `yaml:"command,omitempty"`
+	// URL is the HTTP endpoint the payload is posted to.
+	URL string `yaml:"url,omitempty"`
+}
+
+// Validate checks that every hook sets either a command or a valid http(s)
+// URL.
+func (h Hooks) Validate() error {
+	events := []struct {
+		name  string
+		hooks []Hook
+	}{
+		{"on_crash", h.OnCrash},
+		{"on_finish", h.OnFinish},
+		{"on_plateau", h.OnPlateau},
+	}
+	for _, event := range events {
+		for i, hook := range event.hooks {
+			if err := hook.Validate(); err != nil {
+				return xerrors.Errorf("%s[%d]: %w", event.name, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Validate checks that the hook sets either a command or a valid http(s) URL.
+func (h Hook) Validate() error {
+	switch {
+	case h.Command != "" && h.URL != "":
+		return xerrors.New("only one of command and url can be set")
+	case h.Command == "" && h.URL == "":
+		return xerrors.New("one of command and url must be set")
+	case h.URL == "":
+		return nil
+	}
+	u, err := url.Parse(h.URL)
+	if err != nil {
+		return xerrors.Errorf("invalid url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return xerrors.Errorf("url must be an absolute http(s) url: %q", h.URL)
+	}
+	return nil
+}
+
+// Plateau actions.
+const (
+	// PlateauContinue keeps fuzzing, only the hooks are run.
+	PlateauContinue = "continue"
+	// PlateauStop stops fuzzing the target.
+	PlateauStop = "stop"
+	// PlateauNice lowers the scheduling priority of go-fuzz, such that other
+	// targets on the same machine get more CPU time.
+	PlateauNice = "nice"
+)
+
+// Plateau configures when fuzzing is considered to have plateaued, and what
+// happens when it does.
+type Plateau struct {
+	// Window is the duration without coverage growth. If it is not set,
+	// DefaultPlateauWindow is used.
+	Window time.Duration `yaml:"window,omitempty"`
+	// CorpusWindow is the duration without corpus growth. If it is set,
+	// fuzzing has only plateaued if the corpus did not grow within this
+	// window either.
+	CorpusWindow time.Duration `yaml:"corpus_window,omitempty"`
+	// Action is taken when fuzzing plateaus. If it is not set,
+	// PlateauContinue is used.
+	Action string `yaml:"action,omitempty"`
+}
+
+// Validate checks that the windows are not negative and that the action is
+// known.
+func (p Plateau) Validate() error {
+	if p.Window < 0 {
+		return xerrors.Errorf("window must not be negative: %s", p.Window)
+	}
+	if p.CorpusWindow < 0 {
+		return xerrors.Errorf("corpus_window must not be negative: %s", p.CorpusWindow)
+	}
+	switch p.Action {
+	case "", PlateauContinue, PlateauStop, PlateauNice:
+	default:
+		return xerrors.Errorf("unknown action %q, must be one of %q, %q or %q", p.Action,
+			PlateauContinue, PlateauStop, PlateauNice)
+	}
+	return nil
+}
+
+// EffectiveWindow returns the window, or the default if it is not set.
+func (p Plateau) EffectiveWindow() time.Duration {
+	if p.Window == 0 {
+		return DefaultPlateauWindow
+	}
+	return p.Window
+}
+
+// EffectiveAction returns the action, or the default if it is not set.
+func (p Plateau) EffectiveAction() string {
+	if p.Action == "" {
+		return PlateauContinue
+	}
+	return p.Action
+}
@@ -0,0 +1,315 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package conf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+	"gopkg.in/yaml.v3"
+)
+
+// Position is a location in a config file.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	switch {
+	case p.Line == 0:
+		return p.File
+	case p.Column == 0:
+		return fmt.Sprintf("%s:%d", p.File, p.Line)
+	default:
+		return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+	}
+}
+
+// Problem is a single issue found in a config file.
+type Problem struct {
+	Pos Position
+	Msg string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Pos, p.Msg)
+}
+
+// Problems is a list of problems. It implements the error interface.
+type Problems []Problem
+
+func (p Problems) Error() string {
+	lines := make([]string, len(p))
+	for i, problem := range p {
+		lines[i] = problem.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// File is a strictly parsed config file.
+type File struct {
+	// Name is the file name used in positions.
+	Name string
+	// Conf is the parsed config. It might be partial if problems were found.
+	Conf Conf
+
+	root yaml.Node
+}
+
+// Load reads and strictly parses the config file. Unknown keys, type
+// mismatches and missing required fields result in an error.
+func Load(file string) (Conf, error) {
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return Conf{}, xerrors.Errorf("unable to read config file at %s: %w", file, err)
+	}
+	f, problems := Parse(file, raw)
+	problems = append(problems, f.Check()...)
+	if len(problems) > 0 {
+		return Conf{}, xerrors.Errorf("invalid config file at %s:\n%w", file, problems)
+	}
+	return f.Conf, nil
+}
+
+// Parse strictly parses the raw config. The returned problems contain unknown
+// keys, type mismatches and syntax errors. Required fields are not checked,
+// see Check.
+func Parse(name string, raw []byte) (*File, Problems) {
+	f := &File{Name: name}
+	if err := yaml.Unmarshal(raw, &f.root); err != nil {
+		return f, f.yamlProblems(err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	// On type errors, the config is still partially decoded.
+	if err := dec.Decode(&f.Conf); err != nil && err != io.EOF {
+		return f, f.yamlProblems(err)
+	}
+	return f, nil
+}
+
+// AddTargets appends the targets to the config file. Existing content,
+// including comments, is preserved.
+func (f *File) AddTargets(targets ...Target) error {
+	if len(f.root.Content) == 0 {
+		f.root = yaml.Node{
+			Kind:    yaml.DocumentNode,
+			Content: []*yaml.Node{{Kind: yaml.MappingNode}},
+		}
+	}
+	doc := f.root.Content[0]
+	_, seq := mappingEntry(doc, "targets")
+	if seq == nil {
+		seq = &yaml.Node{Kind: yaml.SequenceNode}
+		doc.Content = append(doc.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: "targets"}, seq)
+	}
+	if seq.Kind != yaml.SequenceNode {
+		return xerrors.Errorf("targets is not a list")
+	}
+	for _, target := range targets {
+		if _, ok := f.Conf.Targets[target.Name]; ok {
+			return xerrors.Errorf("target already exists: %s", target.Name)
+		}
+		var node yaml.Node
+		if err := node.Encode(target); err != nil {
+			return xerrors.Errorf("unable to encode target %q: %w", target.Name, err)
+		}
+		seq.Content = append(seq.Content, &node)
+		if f.Conf.Targets == nil {
+			f.Conf.Targets = make(TargetMap)
+		}
+		f.Conf.Targets[target.Name] = target
+		f.Conf.order = append(f.Conf.order, target.Name)
+	}
+	return nil
+}
+
+// Marshal encodes the config file.
+func (f *File) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&f.root); err != nil {
+		return nil, xerrors.Errorf("unable to encode config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, xerrors.Errorf("unable to encode config: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Warnings reports the targets that are ignored by fuzzinator, because they
+// are not go targets.
+func (f *File) Warnings() Problems {
+	var problems Problems
+	for _, name := range f.Conf.Targets.Names() {
+		if err := f.Conf.Targets[name].CheckLanguage(); err != nil {
+			problems = append(problems, f.Problemf(name, "language",
+				"target %q: %s", name, err))
+		}
+	}
+	return problems
+}
+
+// Check reports missing required fields of the parsed go targets. Targets in
+// other languages are skipped, such that a shared fuzzbuzz.io project file
+// can be used, see Warnings.
+func (f *File) Check() Problems {
+	var problems Problems
+	for _, name := range f.Conf.Targets.Names() {
+		target := f.Conf.Targets[name]
+		if !target.IsGo() {
+			continue
+		}
+		required := []struct {
+			key, value string
+		}{
+			{"name", target.Name},
+			{"corpus", target.Corpus},
+			{"harness.function", target.Harness.Function},
+			{"harness.package", target.Harness.Package},
+		}
+		for _, field := range required {
+			if field.value == "" {
+				problems = append(problems, f.Problemf(name, field.key,
+					"target %q: %s must be set", name, field.key))
+			}
+		}
+		if err := target.Harness.Build.Validate(); err != nil {
+			problems = append(problems, f.Problemf(name, "harness.build",
+				"target %q: invalid build: %s", name, err))
+		}
+		if err := target.Options.Validate(); err != nil {
+			problems = append(problems, f.Problemf(name, "options",
+				"target %q: invalid options: %s", name, err))
+		}
+		if err := ValidateExtraArgs(target.ExtraArgs); err != nil {
+			problems = append(problems, f.Problemf(name, "extra_args",
+				"target %q: invalid extra_args: %s", name, err))
+		}
+		if err := target.Hooks.Validate(); err != nil {
+			problems = append(problems, f.Problemf(name, "hooks",
+				"target %q: invalid hooks: %s", name, err))
+		}
+		if err := target.Plateau.Validate(); err != nil {
+			problems = append(problems, f.Problemf(name, "plateau",
+				"target %q: invalid plateau: %s", name, err))
+		}
+		if err := target.Watchdog.Validate(); err != nil {
+			problems = append(problems, f.Problemf(name, "watchdog",
+				"target %q: invalid watchdog: %s", name, err))
+		}
+	}
+	return problems
+}
+
+// Problemf creates a problem located at the key of the given target. The key
+// is a dot separated path, e.g., "harness.package". If the key does not exist,
+// the closest existing parent is used.
+func (f *File) Problemf(target, key, format string, args ...interface{}) Problem {
+	return Problem{Pos: f.Pos(target, key), Msg: fmt.Sprintf(format, args...)}
+}
+
+// Pos returns the position of the key in the target definition. The key is a
+// dot separated path, e.g., "harness.package". An empty key returns the
+// position of the target itself.
+func (f *File) Pos(target, key string) Position {
+	pos := Position{File: f.Name}
+	node := f.targetNode(target)
+	if node == nil {
+		return pos
+	}
+	pos.Line, pos.Column = node.Line, node.Column
+	if key == "" {
+		return pos
+	}
+	for _, part := range strings.Split(key, ".") {
+		keyNode, valueNode := mappingEntry(node, part)
+		if keyNode == nil {
+			return pos
+		}
+		pos.Line, pos.Column = keyNode.Line, keyNode.Column
+		node = valueNode
+	}
+	return pos
+}
+
+func (f *File) targetNode(target string) *yaml.Node {
+	if len(f.root.Content) == 0 {
+		return nil
+	}
+	_, targets := mappingEntry(f.root.Content[0], "targets")
+	if targets == nil || targets.Kind != yaml.SequenceNode {
+		return nil
+	}
+	for _, t := range targets.Content {
+		if _, name := mappingEntry(t, "name"); name != nil && name.Value == target {
+			return t
+		}
+	}
+	return nil
+}
+
+func mappingEntry(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+var yamlLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// yamlProblems converts the yaml error to problems with positions.
+func (f *File) yamlProblems(err error) Problems {
+	var msgs []string
+	var typeErr *yaml.TypeError
+	if xerrors.As(err, &typeErr) {
+		msgs = typeErr.Errors
+	} else {
+		msgs = []string{err.Error()}
+	}
+	problems := make(Problems, 0, len(msgs))
+	for _, msg := range msgs {
+		problem := Problem{Pos: Position{File: f.Name}, Msg: msg}
+		if m := yamlLine.FindStringSubmatch(msg); m != nil {
+			problem.Pos.Line, _ = strconv.Atoi(m[1])
+			problem.Msg = m[2]
+		}
+		problems = append(problems, problem)
+	}
+	return problems
+}
@@ -0,0 +1,121 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package conf
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// reservedArgs are go-fuzz flags that are managed by fuzzinator.
+var reservedArgs = []string{"bin", "workdir", "coordinator", "worker"}
+
+// Options configures the go-fuzz engine. Zero values select the go-fuzz
+// defaults.
+type Options struct {
+	// Procs is the number of parallel fuzzing processes.
+	Procs int `yaml:"procs,omitempty"`
+	// Timeout is the timeout for a single input. It is rounded up to seconds.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// Minimize is the time limit for input minimization.
+	Minimize time.Duration `yaml:"minimize,omitempty"`
+	// DumpCover dumps the coverage profile into the workdir.
+	DumpCover bool `yaml:"dumpcover,omitempty"`
+	// Sonar enables sonar hints. If it is not set, go-fuzz enables them.
+	Sonar *bool `yaml:"sonar,omitempty"`
+	// Verbose is the go-fuzz verbosity level.
+	Verbose int `yaml:"v,omitempty"`
+	// Dict is the path to a dictionary file in AFL/libFuzzer format.
+	Dict string `yaml:"dict,omitempty"`
+}
+
+// Validate checks that the options are in range.
+func (o Options) Validate() error {
+	switch {
+	case o.Procs < 0:
+		return xerrors.Errorf("procs must not be negative: %d", o.Procs)
+	case o.Timeout < 0:
+		return xerrors.Errorf("timeout must not be negative: %s", o.Timeout)
+	case o.Minimize < 0:
+		return xerrors.Errorf("minimize must not be negative: %s", o.Minimize)
+	case o.Verbose < 0:
+		return xerrors.Errorf("v must not be negative: %d", o.Verbose)
+	}
+	return nil
+}
+
+// Args returns the go-fuzz command line arguments for the options.
+func (o Options) Args() []string {
+	var args []string
+	if o.Procs > 0 {
+		args = append(args, fmt.Sprintf("-procs=%d", o.Procs))
+	}
+	if o.Timeout > 0 {
+		secs := (o.Timeout + time.Second - 1) / time.Second
+		args = append(args, fmt.Sprintf("-timeout=%d", secs))
+	}
+	if o.Minimize > 0 {
+		args = append(args, fmt.Sprintf("-minimize=%s", o.Minimize))
+	}
+	if o.DumpCover {
+		args = append(args, "-dumpcover")
+	}
+	if o.Sonar != nil {
+		args = append(args, fmt.Sprintf("-sonar=%t", *o.Sonar))
+	}
+	if o.Verbose > 0 {
+		args = append(args, fmt.Sprintf("-v=%d", o.Verbose))
+	}
+	if o.Dict != "" {
+		args = append(args, fmt.Sprintf("-dict=%s", o.Dict))
+	}
+	return args
+}
+
+// FuzzArgs returns the go-fuzz command line arguments for the target. The
+// extra arguments are appended after the typed options.
+func (t Target) FuzzArgs() []string {
+	return append(t.Options.Args(), t.ExtraArgs...)
+}
+
+// ValidateExtraArgs checks that no flag managed by fuzzinator is set.
+func ValidateExtraArgs(args []string) error {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name := strings.TrimLeft(arg, "-")
+		if i := strings.Index(name, "="); i >= 0 {
+			name = name[:i]
+		}
+		for _, reserved := range reservedArgs {
+			if name == reserved {
+				return xerrors.Errorf("flag -%s is managed by fuzzinator", name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package conf
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"regexp"
+
+	"golang.org/x/xerrors"
+	"gopkg.in/yaml.v3"
+)
+
+// Suppression describes crashers that are accepted behavior, e.g., intentional
+// panics on invariant violations. A crasher is suppressed if it matches all of
+// the set fields.
+type Suppression struct {
+	// Signature is the crash signature as reported by the crashers command.
+	Signature string `yaml:"signature,omitempty"`
+	// Panic is a regular expression matched against the panic message.
+	Panic string `yaml:"panic,omitempty"`
+	// Stack is a regular expression matched against the functions on the
+	// stack of the crashing goroutine. It matches if any function matches.
+	Stack string `yaml:"stack,omitempty"`
+	// Reason documents why the crasher is accepted.
+	Reason string `yaml:"reason,omitempty"`
+
+	// panicRe and stackRe are the compiled patterns, see Compile.
+	panicRe *regexp.Regexp
+	stackRe *regexp.Regexp
+}
+
+// Compile checks that at least one pattern is set and compiles the regular
+// expressions. Suppressions returned by LoadSuppressions are compiled.
+func (s *Suppression) Compile() error {
+	if s.Signature == "" && s.Panic == "" && s.Stack == "" {
+		return xerrors.New("one of signature, panic or stack must be set")
+	}
+	panicRe, err := regexp.Compile(s.Panic)
+	if err != nil {
+		return xerrors.Errorf("invalid panic pattern: %w", err)
+	}
+	stackRe, err := regexp.Compile(s.Stack)
+	if err != nil {
+		return xerrors.Errorf("invalid stack pattern: %w", err)
+	}
+	s.panicRe, s.stackRe = panicRe, stackRe
+	return nil
+}
+
+// Validate checks that at least one pattern is set and that the regular
+// expressions compile.
+func (s Suppression) Validate() error {
+	return s.Compile()
+}
+
+// Matches indicates whether the crash with the signature, panic message and
+// stack functions is suppressed. Suppressions that were not compiled are
+// compiled on every call, and never match if they are invalid.
+func (s Suppression) Matches(signature, panicMsg string, stack []string) bool {
+	if s.panicRe == nil || s.stackRe == nil {
+		if err := s.Compile(); err != nil {
+			return false
+		}
+	}
+	if s.Signature != "" && s.Signature != signature {
+		return false
+	}
+	if s.Panic != "" && !s.panicRe.MatchString(panicMsg) {
+		return false
+	}
+	if s.Stack == "" {
+		return true
+	}
+	for _, fn := range stack {
+		if s.stackRe.MatchString(fn) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadSuppressions strictly parses the suppression file, which contains a
+// list of suppressions. The patterns are compiled once, invalid patterns
+// result in an error.
+func LoadSuppressions(file string) ([]Suppression, error) {
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, xerrors.Errorf("unable to read suppression file at %s: %w", file, err)
+	}
+	var suppressions []Suppression
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	if err := dec.Decode(&suppressions); err != nil && err != io.EOF {
+		return nil, xerrors.Errorf("invalid suppression file at %s: %w", file, err)
+	}
+	for i := range suppressions {
+		if err := suppressions[i].Compile(); err != nil {
+			return nil, xerrors.Errorf("invalid suppression file at %s: entry %d: %w",
+				file, i+1, err)
+		}
+	}
+	return suppressions, nil
+}
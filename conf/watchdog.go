@@ -0,0 +1,69 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package conf
+
+import (
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// DefaultMaxRestarts is the default number of times the watchdog restarts
+// go-fuzz.
+const DefaultMaxRestarts = 3
+
+// Watchdog configures when go-fuzz is restarted on the same workdir. By
+// default, go-fuzz is never restarted.
+type Watchdog struct {
+	// Stall is the duration without fuzzing progress, i.e., without a status
+	// line that reports a non-zero exec rate, after which go-fuzz is
+	// restarted. If it is not set, stalls are not detected. Stalls are not
+	// detected in coordinator mode, where no workers might be connected.
+	Stall time.Duration `yaml:"stall,omitempty"`
+	// RestartOnExit restarts go-fuzz when it exits unexpectedly.
+	RestartOnExit bool `yaml:"restart_on_exit,omitempty"`
+	// MaxRestarts is the number of restarts after which fuzzing is given up.
+	// If it is not set, DefaultMaxRestarts is used.
+	MaxRestarts int `yaml:"max_restarts,omitempty"`
+}
+
+// Validate checks that the stall duration and the restart cap are not
+// negative.
+func (w Watchdog) Validate() error {
+	if w.Stall < 0 {
+		return xerrors.Errorf("stall must not be negative: %s", w.Stall)
+	}
+	if w.MaxRestarts < 0 {
+		return xerrors.Errorf("max_restarts must not be negative: %d", w.MaxRestarts)
+	}
+	return nil
+}
+
+// EffectiveMaxRestarts returns the restart cap, or the default if it is not
+// set.
+func (w Watchdog) EffectiveMaxRestarts() int {
+	if w.MaxRestarts == 0 {
+		return DefaultMaxRestarts
+	}
+	return w.MaxRestarts
+}
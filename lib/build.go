@@ -0,0 +1,118 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"golang.org/x/xerrors"
+
+	"github.com/oncilla/fuzzinator/conf"
+)
+
+// BuildConfig is the effective configuration used to build a fuzzing binary.
+type BuildConfig struct {
+	Package  string   `json:"package"`
+	Function string   `json:"function"`
+	Tags     string   `json:"tags"`
+	Args     []string `json:"args"`
+	Env      []string `json:"env"`
+	// GoVersion is the version of the go toolchain. It is set by Resolve.
+	GoVersion string `json:"go_version,omitempty"`
+	// GoFuzzBuild is the version of go-fuzz-build. It is set by Resolve.
+	GoFuzzBuild string `json:"go_fuzz_build,omitempty"`
+	// BuildEnv contains the effective go env variables that affect the
+	// binary, including the ones inherited from the environment. It is set by
+	// Resolve.
+	BuildEnv []string `json:"build_env,omitempty"`
+	// Sources is the content hash of the transitive sources of the package.
+	// It is set by Resolve.
+	Sources string `json:"sources,omitempty"`
+}
+
+// NewBuildConfig returns the effective build configuration of the target.
+func NewBuildConfig(target conf.Target) BuildConfig {
+	return BuildConfig{
+		Package:  target.Harness.Package,
+		Function: target.Harness.Function,
+		Tags:     target.Harness.BuildTags,
+		Args:     target.Harness.Build.Args(),
+		Env:      target.Harness.Build.Environ(),
+	}
+}
+
+// Key returns the cache key of the build configuration. Call Resolve first
+// to include the toolchain, the build environment and the sources in the key.
+func (c BuildConfig) Key() string {
+	raw, err := json.Marshal(c)
+	if err != nil {
+		// Marshalling a struct of strings cannot fail.
+		panic(err)
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// BuildConfigPath returns the path of the build configuration that was used to
+// build the fuzzing binary in the workdir.
+func BuildConfigPath(workdir string) string {
+	return BinaryPath(workdir) + ".json"
+}
+
+// WriteBuildConfig writes the build configuration to the file.
+func WriteBuildConfig(file string, c BuildConfig) error {
+	raw, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return xerrors.Errorf("unable to encode build config: %w", err)
+	}
+	if err := ioutil.WriteFile(file, raw, 0644); err != nil {
+		return xerrors.Errorf("unable to write build config: %w", err)
+	}
+	return nil
+}
+
+// ReadBuildConfig reads the build configuration from the file.
+func ReadBuildConfig(file string) (BuildConfig, error) {
+	var c BuildConfig
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return c, xerrors.Errorf("unable to read build config: %w", err)
+	}
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return c, xerrors.Errorf("unable to decode build config: %w", err)
+	}
+	return c, nil
+}
+
+func (c BuildConfig) harness() conf.Harness {
+	return conf.Harness{Package: c.Package, Function: c.Function, BuildTags: c.Tags}
+}
+
+// buildEnv returns the environment for go-fuzz-build.
+func buildEnv(c BuildConfig) []string {
+	return append(os.Environ(), c.Env...)
+}
@@ -0,0 +1,206 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/xerrors"
+)
+
+// BuildCache is a content addressed cache of fuzzing binaries. Binaries are
+// keyed by the build key of their build configuration.
+type BuildCache struct {
+	Dir string
+}
+
+// DefaultBuildCacheDir returns the default build cache directory in the user
+// cache directory.
+func DefaultBuildCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "fuzzinator", "builds")
+}
+
+// Lookup returns the path of the cached binary for the key. The boolean
+// indicates whether the binary is cached.
+func (c BuildCache) Lookup(key string) (string, bool) {
+	bin := BinaryPath(filepath.Join(c.Dir, key))
+	if _, err := os.Stat(bin); err != nil {
+		return "", false
+	}
+	return bin, true
+}
+
+// Store adds the binary and its build configuration to the cache.
+func (c BuildCache) Store(cfg BuildConfig, bin string) error {
+	dir := filepath.Join(c.Dir, cfg.Key())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return xerrors.Errorf("unable to create cache dir: %w", err)
+	}
+	if err := WriteBuildConfig(BuildConfigPath(dir), cfg); err != nil {
+		return err
+	}
+	if err := copyFile(bin, BinaryPath(dir)); err != nil {
+		return xerrors.Errorf("unable to store binary in cache: %w", err)
+	}
+	return nil
+}
+
+// Restore copies the cached binary for the build configuration to the
+// workdir. The boolean indicates whether the binary was cached.
+func (c BuildCache) Restore(cfg BuildConfig, workdir string) (bool, error) {
+	cached, ok := c.Lookup(cfg.Key())
+	if !ok {
+		return false, nil
+	}
+	if err := copyFile(cached, BinaryPath(workdir)); err != nil {
+		return false, xerrors.Errorf("unable to restore binary from cache: %w", err)
+	}
+	if err := WriteBuildConfig(BuildConfigPath(workdir), cfg); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Resolve sets the go toolchain, the go-fuzz-build version, the effective
+// build environment and the content hash of the transitive sources of the
+// harness package. All of them are part of the build key.
+func (c *BuildConfig) Resolve() error {
+	version, err := GoVersion()
+	if err != nil {
+		return err
+	}
+	c.GoVersion = version
+	if c.GoFuzzBuild, err = GoFuzzBuildVersion(); err != nil {
+		return err
+	}
+	if c.BuildEnv, err = GoEnv(buildEnv(*c)); err != nil {
+		return err
+	}
+	sources, err := SourceHash(*c)
+	if err != nil {
+		return err
+	}
+	c.Sources = sources
+	return nil
+}
+
+// SourceHash returns the content hash of the go sources of the package in the
+// build configuration and all its non-standard library dependencies.
+func SourceHash(c BuildConfig) (string, error) {
+	goroot, err := exec.Command("go", "env", "GOROOT").Output()
+	if err != nil {
+		return "", xerrors.Errorf("unable to determine GOROOT: %w", err)
+	}
+	stdlib := filepath.Clean(strings.TrimSpace(string(goroot))) + string(filepath.Separator)
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedImports |
+			packages.NeedDeps,
+		BuildFlags: []string{"-tags", BuildTags(c.harness())},
+		Env:        buildEnv(c),
+	}
+	pkgs, err := packages.Load(cfg, c.Package)
+	if err != nil {
+		return "", xerrors.Errorf("unable to load package: %w", err)
+	}
+	var entries []string
+	var loadErr error
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if len(pkg.Errors) > 0 && loadErr == nil {
+			loadErr = xerrors.Errorf("unable to load %q: %s", pkg.PkgPath, pkg.Errors[0])
+		}
+		files := append(append([]string{}, pkg.GoFiles...), pkg.OtherFiles...)
+		for _, file := range files {
+			if strings.HasPrefix(file, stdlib) {
+				continue
+			}
+			sum, err := fileHash(file)
+			if err != nil && loadErr == nil {
+				loadErr = err
+			}
+			// Use the import path instead of the absolute path, such that
+			// different checkouts of the same sources share the cache.
+			entries = append(entries, fmt.Sprintf("%s/%s %s", pkg.PkgPath,
+				filepath.Base(file), sum))
+		}
+	})
+	if loadErr != nil {
+		return "", loadErr
+	}
+	sort.Strings(entries)
+	h := sha256.New()
+	for _, entry := range entries {
+		fmt.Fprintln(h, entry)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func fileHash(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", xerrors.Errorf("unable to open file: %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", xerrors.Errorf("unable to hash file: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// copyFile copies the file to a temporary file next to dst and renames it,
+// such that concurrent readers never see a partial file.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp, err := ioutil.TempFile(filepath.Dir(dst), filepath.Base(dst)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
@@ -0,0 +1,129 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/oncilla/fuzzinator/lib"
+)
+
+func TestBuildCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fuzzinator-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer stubGoFuzzBuild(t, dir, "v1")()
+
+	cfg := lib.BuildConfig{
+		Package:  "github.com/oncilla/fuzzinator/test",
+		Function: "Fuzz",
+	}
+	require.NoError(t, cfg.Resolve())
+	assert.NotEmpty(t, cfg.GoVersion)
+	assert.NotEmpty(t, cfg.GoFuzzBuild)
+	assert.NotEmpty(t, cfg.BuildEnv)
+	assert.NotEmpty(t, cfg.Sources)
+
+	cache := lib.BuildCache{Dir: filepath.Join(dir, "cache")}
+	workdir := filepath.Join(dir, "workdir")
+	require.NoError(t, os.MkdirAll(workdir, 0755))
+	hit, err := cache.Restore(cfg, workdir)
+	require.NoError(t, err)
+	assert.False(t, hit)
+
+	bin := filepath.Join(dir, "fuzz.zip")
+	require.NoError(t, ioutil.WriteFile(bin, []byte("binary"), 0644))
+	require.NoError(t, cache.Store(cfg, bin))
+	hit, err = cache.Restore(cfg, workdir)
+	require.NoError(t, err)
+	assert.True(t, hit)
+	raw, err := ioutil.ReadFile(lib.BinaryPath(workdir))
+	require.NoError(t, err)
+	assert.Equal(t, "binary", string(raw))
+	restored, err := lib.ReadBuildConfig(lib.BuildConfigPath(workdir))
+	require.NoError(t, err)
+	assert.Equal(t, cfg.Key(), restored.Key())
+
+	cfg.Args = []string{"-race"}
+	_, ok := cache.Lookup(cfg.Key())
+	assert.False(t, ok)
+}
+
+func TestBuildConfigResolve(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fuzzinator-resolve")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer stubGoFuzzBuild(t, dir, "v1")()
+
+	resolve := func() lib.BuildConfig {
+		cfg := lib.BuildConfig{
+			Package:  "github.com/oncilla/fuzzinator/test",
+			Function: "Fuzz",
+		}
+		require.NoError(t, cfg.Resolve())
+		return cfg
+	}
+	key := resolve().Key()
+	assert.Equal(t, key, resolve().Key())
+
+	t.Run("go-fuzz-build", func(t *testing.T) {
+		defer stubGoFuzzBuild(t, dir, "v2")()
+		assert.NotEqual(t, key, resolve().Key())
+	})
+	t.Run("environment", func(t *testing.T) {
+		defer setenv(t, "GOARCH", "386")()
+		cfg := resolve()
+		assert.Contains(t, cfg.BuildEnv, "GOARCH=386")
+		assert.NotEqual(t, key, cfg.Key())
+	})
+}
+
+// stubGoFuzzBuild puts a go-fuzz-build stub with the content on the PATH and
+// returns a function that restores the environment.
+func stubGoFuzzBuild(t *testing.T, dir, content string) func() {
+	bin := filepath.Join(dir, "go-fuzz-build-"+content)
+	require.NoError(t, os.MkdirAll(bin, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(bin, "go-fuzz-build"),
+		[]byte("#!/bin/sh\n# "+content+"\n"), 0755))
+	return setenv(t, "PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// setenv sets the environment variable and returns a function that restores
+// it.
+func setenv(t *testing.T, key, value string) func() {
+	old, ok := os.LookupEnv(key)
+	require.NoError(t, os.Setenv(key, value))
+	return func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
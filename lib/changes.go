@@ -0,0 +1,114 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib
+
+import (
+	"sort"
+	"strings"
+
+	"golang.org/x/xerrors"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/diff"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// ChangedLines returns the lines that were added or modified between the merge
+// base of the base and head revision and the head revision in the git
+// repository at dir. The lines are indexed by the slash separated file path
+// relative to the repository root, and refer to the file at head. Deleted and
+// binary files are omitted.
+func ChangedLines(dir, base, head string) (map[string][]int, error) {
+	r, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, xerrors.Errorf("unable to open git repository at %q: %w", dir, err)
+	}
+	resolve := func(rev string) (*object.Commit, error) {
+		hash, err := r.ResolveRevision(plumbing.Revision(rev))
+		if err != nil {
+			return nil, xerrors.Errorf("unable to resolve revision %q: %w", rev, err)
+		}
+		c, err := r.CommitObject(*hash)
+		if err != nil {
+			return nil, xerrors.Errorf("unable to resolve commit %s: %w", hash, err)
+		}
+		return c, nil
+	}
+	baseCommit, err := resolve(base)
+	if err != nil {
+		return nil, err
+	}
+	headCommit, err := resolve(head)
+	if err != nil {
+		return nil, err
+	}
+	// Only the changes on the head branch are of interest, not the ones that
+	// were added to the base branch in the meantime.
+	bases, err := baseCommit.MergeBase(headCommit)
+	if err != nil {
+		return nil, xerrors.Errorf("unable to determine merge base: %w", err)
+	}
+	if len(bases) > 0 {
+		baseCommit = bases[0]
+	}
+	patch, err := baseCommit.Patch(headCommit)
+	if err != nil {
+		return nil, xerrors.Errorf("unable to compute diff: %w", err)
+	}
+	changed := make(map[string][]int)
+	for _, fp := range patch.FilePatches() {
+		_, to := fp.Files()
+		if to == nil || fp.IsBinary() {
+			continue
+		}
+		var lines []int
+		line := 1
+		for _, chunk := range fp.Chunks() {
+			n := countLines(chunk.Content())
+			switch chunk.Type() {
+			case diff.Equal:
+				line += n
+			case diff.Add:
+				for i := 0; i < n; i++ {
+					lines = append(lines, line+i)
+				}
+				line += n
+			}
+		}
+		if len(lines) > 0 {
+			sort.Ints(lines)
+			changed[to.Path()] = lines
+		}
+	}
+	return changed, nil
+}
+
+// countLines returns the number of lines in the content. The last line does
+// not need to be terminated by a newline.
+func countLines(content string) int {
+	n := strings.Count(content, "\n")
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,108 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/xerrors"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// RepoRoot returns the root of the worktree of the git repository at dir.
+func RepoRoot(dir string) (string, error) {
+	r, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return "", xerrors.Errorf("unable to open git repository at %q: %w", dir, err)
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return "", xerrors.Errorf("unable to get worktree: %w", err)
+	}
+	return w.Filesystem.Root(), nil
+}
+
+// CheckoutCommit writes the tree of the revision in the git repository at dir
+// to dst. The checkout is isolated, i.e., neither the worktree nor the index
+// of the repository are touched. The resolved commit hash is returned.
+func CheckoutCommit(dir, rev, dst string) (string, error) {
+	r, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return "", xerrors.Errorf("unable to open git repository at %q: %w", dir, err)
+	}
+	hash, err := r.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return "", xerrors.Errorf("unable to resolve revision %q: %w", rev, err)
+	}
+	c, err := r.CommitObject(*hash)
+	if err != nil {
+		return "", xerrors.Errorf("unable to resolve commit %s: %w", hash, err)
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return "", xerrors.Errorf("unable to get tree of commit %s: %w", hash, err)
+	}
+	err = tree.Files().ForEach(func(f *object.File) error {
+		return checkoutFile(f, filepath.Join(dst, filepath.FromSlash(f.Name)))
+	})
+	if err != nil {
+		return "", xerrors.Errorf("unable to checkout commit %s: %w", hash, err)
+	}
+	return hash.String(), nil
+}
+
+func checkoutFile(f *object.File, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if f.Mode == filemode.Symlink {
+		target, err := f.Contents()
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, path)
+	}
+	perm := os.FileMode(0644)
+	if f.Mode == filemode.Executable {
+		perm = 0755
+	}
+	src, err := f.Reader()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
@@ -0,0 +1,147 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+
+	"github.com/oncilla/fuzzinator/lib"
+)
+
+func TestCheckoutCommit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fuzzinator-checkout")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	repo := filepath.Join(dir, "repo")
+	commit := initRepo(t, repo)
+	first := commit(map[string]string{"sub/file": "first"})
+	second := commit(map[string]string{"sub/file": "second"})
+
+	root, err := lib.RepoRoot(filepath.Join(repo, "sub"))
+	require.NoError(t, err)
+	assert.Equal(t, repo, root)
+
+	for rev, expected := range map[string]struct{ hash, content string }{
+		"HEAD~1": {first, "first"},
+		"master": {second, "second"},
+	} {
+		dst := filepath.Join(dir, rev)
+		hash, err := lib.CheckoutCommit(repo, rev, dst)
+		require.NoError(t, err, rev)
+		assert.Equal(t, expected.hash, hash, rev)
+		raw, err := ioutil.ReadFile(filepath.Join(dst, "sub", "file"))
+		require.NoError(t, err, rev)
+		assert.Equal(t, expected.content, string(raw), rev)
+	}
+	raw, err := ioutil.ReadFile(filepath.Join(repo, "sub", "file"))
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(raw))
+}
+
+func TestChangedLines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fuzzinator-changes")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	commit := initRepo(t, dir)
+	commit(map[string]string{
+		"a.go":    "1\n2\n3\n4\n",
+		"b.go":    "1\n",
+		"gone.go": "1\n",
+	})
+	r, err := git.PlainOpen(dir)
+	require.NoError(t, err)
+	head, err := r.Head()
+	require.NoError(t, err)
+	require.NoError(t, r.Storer.SetReference(
+		plumbing.NewHashReference("refs/heads/base", head.Hash())))
+	require.NoError(t, os.Remove(filepath.Join(dir, "gone.go")))
+	commit(map[string]string{
+		"a.go":     "1\nnew\n3\n4\nappended",
+		"sub/c.go": "1\n2\n",
+	})
+
+	changed, err := lib.ChangedLines(dir, "base", "HEAD")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]int{
+		"a.go":     {2, 5},
+		"sub/c.go": {1, 2},
+	}, changed)
+}
+
+// initRepo initializes a git repository at dir. The returned function writes
+// the files, relative to dir, and commits all changes in the worktree.
+func TestUnreachableCommits(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fuzzinator-unreachable")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	commit := initRepo(t, dir)
+	first := commit(map[string]string{"a.go": "package a"})
+	dropped := commit(map[string]string{"a.go": "package a // dropped"})
+	// Reset master to the first commit, such that the second one is only in
+	// the object store.
+	r, err := git.PlainOpen(dir)
+	require.NoError(t, err)
+	require.NoError(t, r.Storer.SetReference(plumbing.NewHashReference(
+		plumbing.Master, plumbing.NewHash(first))))
+	foreign := strings.Repeat("ab", 20)
+
+	unreachable, err := lib.UnreachableCommits(dir, []string{first, dropped, foreign})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{dropped: true}, unreachable)
+}
+
+func initRepo(t *testing.T, dir string) func(files map[string]string) string {
+	r, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+	return func(files map[string]string) string {
+		for name, content := range files {
+			file := filepath.Join(dir, filepath.FromSlash(name))
+			require.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+			require.NoError(t, ioutil.WriteFile(file, []byte(content), 0644))
+		}
+		_, err := w.Add(".")
+		require.NoError(t, err)
+		hash, err := w.Commit("commit", &git.CommitOptions{
+			All:    true,
+			Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		})
+		require.NoError(t, err)
+		return hash.String()
+	}
+}
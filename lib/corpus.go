@@ -0,0 +1,174 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/xerrors"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+// CorpusDir returns the corpus directory in the workdir.
+func CorpusDir(workdir string) string {
+	return filepath.Join(workdir, "corpus")
+}
+
+// TargetWorkdirs returns the workdirs of the target indexed by commit.
+func TargetWorkdirs(targetName string) (map[string]string, error) {
+	prefix := targetName + "_"
+	matches, err := filepath.Glob(filepath.Join(WorkdirRoot(), prefix+"*"))
+	if err != nil {
+		return nil, xerrors.Errorf("unable to list workdirs: %w", err)
+	}
+	workdirs := make(map[string]string, len(matches))
+	for _, workdir := range matches {
+		commit := strings.TrimPrefix(filepath.Base(workdir), prefix)
+		// Targets with the same prefix, e.g., "a" and "a_b", share the glob.
+		if meta, err := ReadWorkdirMeta(workdir); err == nil && meta.Target != targetName {
+			continue
+		}
+		if !isCommitHash(commit) {
+			continue
+		}
+		workdirs[commit] = workdir
+	}
+	return workdirs, nil
+}
+
+// PreviousWorkdir returns the workdir of the target whose commit is the
+// closest ancestor of the given commit in the git repository at dir. An empty
+// string is returned if there is none.
+func PreviousWorkdir(dir, targetName, commit string) (string, error) {
+	workdirs, err := TargetWorkdirs(targetName)
+	if err != nil {
+		return "", err
+	}
+	delete(workdirs, commit)
+	if len(workdirs) == 0 {
+		return "", nil
+	}
+	r, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return "", xerrors.Errorf("unable to open git repository at %q: %w", dir, err)
+	}
+	head, err := r.CommitObject(plumbing.NewHash(commit))
+	if err != nil {
+		return "", xerrors.Errorf("unable to resolve commit %s: %w", commit, err)
+	}
+	// Breadth first search finds the closest ancestor.
+	var previous string
+	iter := object.NewCommitIterBSF(head, nil, nil)
+	err = iter.ForEach(func(c *object.Commit) error {
+		if workdir, ok := workdirs[c.Hash.String()]; ok {
+			previous = workdir
+			return storer.ErrStop
+		}
+		return nil
+	})
+	if err != nil {
+		return "", xerrors.Errorf("unable to walk commit history: %w", err)
+	}
+	return previous, nil
+}
+
+// SeedCorpus copies the corpus entries from the src directory to the corpus
+// of the workdir. Entries are deduplicated by content hash and named by their
+// SHA1 hash, like go-fuzz does. The number of added entries is returned.
+func SeedCorpus(src, workdir string) (int, error) {
+	dst := CorpusDir(workdir)
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return 0, xerrors.Errorf("unable to create corpus dir: %w", err)
+	}
+	known, err := corpusHashes(dst)
+	if err != nil {
+		return 0, err
+	}
+	files, err := ioutil.ReadDir(src)
+	if err != nil {
+		return 0, xerrors.Errorf("unable to read corpus: %w", err)
+	}
+	var added int
+	for _, file := range files {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		raw, err := ioutil.ReadFile(filepath.Join(src, file.Name()))
+		if err != nil {
+			return added, xerrors.Errorf("unable to read corpus entry: %w", err)
+		}
+		hash := contentHash(raw)
+		if known[hash] {
+			continue
+		}
+		if err := ioutil.WriteFile(filepath.Join(dst, hash), raw, 0644); err != nil {
+			return added, xerrors.Errorf("unable to write corpus entry: %w", err)
+		}
+		known[hash] = true
+		added++
+	}
+	return added, nil
+}
+
+// corpusHashes returns the content hashes of all entries in the corpus dir.
+func corpusHashes(dir string) (map[string]bool, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, xerrors.Errorf("unable to read corpus: %w", err)
+	}
+	hashes := make(map[string]bool, len(files))
+	for _, file := range files {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		raw, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, xerrors.Errorf("unable to read corpus entry: %w", err)
+		}
+		hashes[contentHash(raw)] = true
+	}
+	return hashes, nil
+}
+
+func isCommitHash(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// contentHash returns the hex encoded SHA1 hash, which go-fuzz uses to name
+// corpus entries and crashers.
+func contentHash(raw []byte) string {
+	sum := sha1.Sum(raw)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,64 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/oncilla/fuzzinator/lib"
+)
+
+func TestSeedCorpus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fuzzinator-corpus")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "src")
+	workdir := filepath.Join(dir, "workdir")
+	require.NoError(t, os.MkdirAll(src, 0755))
+	require.NoError(t, os.MkdirAll(lib.CorpusDir(workdir), 0755))
+	write := func(dir, name, content string) {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	write(lib.CorpusDir(workdir), "seed.json", `{"A": 1}`)
+	write(src, "dup", `{"A": 1}`)
+	write(src, "new1", `{"A": 2}`)
+	write(src, "new2", `{"A": 2}`)
+
+	added, err := lib.SeedCorpus(src, workdir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, added)
+	files, err := ioutil.ReadDir(lib.CorpusDir(workdir))
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	added, err = lib.SeedCorpus(src, workdir)
+	require.NoError(t, err)
+	assert.Equal(t, 0, added)
+}
@@ -0,0 +1,365 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// Kinds of entries shared through the corpus server.
+const (
+	KindCorpus   = "corpus"
+	KindCrashers = "crashers"
+)
+
+// maxEntrySize bounds the size of a single entry accepted by the corpus
+// server.
+const maxEntrySize = 16 << 20
+
+var (
+	targetNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	corpusNamePattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+	// Crashers consist of the input, and the .quoted and .output files.
+	crasherNamePattern = regexp.MustCompile(`^[0-9a-f]{40}(\.quoted|\.output)?$`)
+)
+
+// DefaultCorpusServerDir returns the default storage directory of the corpus
+// server in the user cache directory.
+func DefaultCorpusServerDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "fuzzinator", "corpus-server")
+}
+
+// CorpusServer shares corpus entries and crashers between fuzzing machines.
+// Entries are stored per target and kind, and are keyed by their SHA1 content
+// hash, like go-fuzz names them. It serves:
+//
+//	GET /v1/<target>/<kind>/         lists the entry names, one per line
+//	GET /v1/<target>/<kind>/<name>   returns the entry
+//	PUT /v1/<target>/<kind>/<name>   stores the entry
+type CorpusServer struct {
+	// Dir is the storage directory.
+	Dir string
+}
+
+func (s *CorpusServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/"), "/")
+	if !strings.HasPrefix(r.URL.Path, "/v1/") || len(parts) != 3 {
+		http.NotFound(w, r)
+		return
+	}
+	target, kind, name := parts[0], parts[1], parts[2]
+	if !targetNamePattern.MatchString(target) || target == "." || target == ".." {
+		http.Error(w, "invalid target name", http.StatusBadRequest)
+		return
+	}
+	var names *regexp.Regexp
+	switch kind {
+	case KindCorpus:
+		names = corpusNamePattern
+	case KindCrashers:
+		names = crasherNamePattern
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	dir := filepath.Join(s.Dir, target, kind)
+	if name == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.list(w, dir)
+		return
+	}
+	if !names.MatchString(name) {
+		http.Error(w, "invalid entry name", http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		http.ServeFile(w, r, filepath.Join(dir, name))
+	case http.MethodPut:
+		s.put(w, r, dir, name)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *CorpusServer) list(w http.ResponseWriter, dir string) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, file := range files {
+		if file.Mode().IsRegular() && !strings.HasPrefix(file.Name(), ".") {
+			fmt.Fprintln(w, file.Name())
+		}
+	}
+}
+
+func (s *CorpusServer) put(w http.ResponseWriter, r *http.Request, dir, name string) {
+	raw, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxEntrySize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	// Inputs are keyed by their content hash, the .quoted and .output files
+	// of crashers by the hash of the input.
+	if corpusNamePattern.MatchString(name) && contentHash(raw) != name {
+		http.Error(w, "content hash does not match name", http.StatusBadRequest)
+		return
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Entries are written atomically, such that they are never listed
+	// partially written.
+	tmp, err := ioutil.TempFile(dir, ".upload")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, name)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CorpusClient shares the corpus and crashers of a target through the corpus
+// server.
+type CorpusClient struct {
+	// Addr is the address of the corpus server, either a host:port pair or an
+	// http(s) URL.
+	Addr   string
+	Target string
+	// Client is the HTTP client. If it is not set, a client with a timeout is
+	// used.
+	Client *http.Client
+}
+
+// SyncStats summarizes a synchronization with the corpus server.
+type SyncStats struct {
+	// Uploaded is the number of uploaded corpus entries.
+	Uploaded int
+	// UploadedCrashers is the number of uploaded crashers.
+	UploadedCrashers int
+	// Pulled is the number of corpus entries pulled into the workdir.
+	Pulled int
+}
+
+// Sync uploads the corpus entries and crashers of the workdir that are not
+// known to the server yet, and pulls the corpus entries of other machines into
+// the workdir corpus. go-fuzz picks up the pulled entries when it is
+// (re)started.
+func (c *CorpusClient) Sync(workdir string) (SyncStats, error) {
+	var stats SyncStats
+	local, err := corpusFiles(CorpusDir(workdir))
+	if err != nil {
+		return stats, err
+	}
+	remote, err := c.List(KindCorpus)
+	if err != nil {
+		return stats, err
+	}
+	for hash, file := range local {
+		if remote[hash] {
+			continue
+		}
+		raw, err := ioutil.ReadFile(file)
+		if err != nil {
+			return stats, xerrors.Errorf("unable to read corpus entry: %w", err)
+		}
+		if err := c.Put(KindCorpus, hash, raw); err != nil {
+			return stats, err
+		}
+		stats.Uploaded++
+	}
+	for hash := range remote {
+		if _, ok := local[hash]; ok {
+			continue
+		}
+		raw, err := c.Get(KindCorpus, hash)
+		if err != nil {
+			return stats, err
+		}
+		if contentHash(raw) != hash {
+			return stats, xerrors.Errorf("corpus entry %s does not match its hash", hash)
+		}
+		err = ioutil.WriteFile(filepath.Join(CorpusDir(workdir), hash), raw, 0644)
+		if err != nil {
+			return stats, xerrors.Errorf("unable to write corpus entry: %w", err)
+		}
+		stats.Pulled++
+	}
+	if stats.UploadedCrashers, err = c.uploadCrashers(workdir); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// uploadCrashers uploads the crashers of the workdir that are not known to
+// the server yet. The input is uploaded last, such that the server only lists
+// complete crashers.
+func (c *CorpusClient) uploadCrashers(workdir string) (int, error) {
+	crashers, err := ListCrashers(workdir)
+	if err != nil || len(crashers) == 0 {
+		return 0, err
+	}
+	remote, err := c.List(KindCrashers)
+	if err != nil {
+		return 0, err
+	}
+	var uploaded int
+	for _, crasher := range crashers {
+		if remote[crasher.Name] {
+			continue
+		}
+		for _, name := range []string{crasher.Name + ".quoted", crasher.Name + ".output",
+			crasher.Name} {
+
+			raw, err := ioutil.ReadFile(filepath.Join(CrashersDir(workdir), name))
+			if os.IsNotExist(err) && name != crasher.Name {
+				continue
+			}
+			if err != nil {
+				return uploaded, xerrors.Errorf("unable to read crasher: %w", err)
+			}
+			if err := c.Put(KindCrashers, name, raw); err != nil {
+				return uploaded, err
+			}
+		}
+		uploaded++
+	}
+	return uploaded, nil
+}
+
+// List returns the names of the entries of the kind on the server.
+func (c *CorpusClient) List(kind string) (map[string]bool, error) {
+	raw, err := c.do(http.MethodGet, kind, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool)
+	for _, name := range strings.Fields(string(raw)) {
+		names[name] = true
+	}
+	return names, nil
+}
+
+// Get returns the entry of the kind from the server.
+func (c *CorpusClient) Get(kind, name string) ([]byte, error) {
+	return c.do(http.MethodGet, kind, name, nil)
+}
+
+// Put stores the entry of the kind on the server.
+func (c *CorpusClient) Put(kind, name string, raw []byte) error {
+	_, err := c.do(http.MethodPut, kind, name, raw)
+	return err
+}
+
+func (c *CorpusClient) do(method, kind, name string, body []byte) ([]byte, error) {
+	addr := c.Addr
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	u := fmt.Sprintf("%s/v1/%s/%s/%s", strings.TrimSuffix(addr, "/"),
+		url.PathEscape(c.Target), kind, name)
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, xerrors.Errorf("invalid corpus server request: %w", err)
+	}
+	client := c.Client
+	if client == nil {
+		client = &http.Client{Timeout: time.Minute}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, xerrors.Errorf("unable to reach corpus server: %w", err)
+	}
+	defer resp.Body.Close()
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, xerrors.Errorf("unable to read corpus server response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, xerrors.Errorf("%s %s failed: %s: %s", method, u, resp.Status,
+			strings.TrimSpace(string(raw)))
+	}
+	return raw, nil
+}
+
+// corpusFiles returns the files of the corpus dir indexed by content hash.
+func corpusFiles(dir string) (map[string]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, xerrors.Errorf("unable to read corpus: %w", err)
+	}
+	hashes := make(map[string]string, len(files))
+	for _, file := range files {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+		// go-fuzz names the entries by their hash, seeded entries might not
+		// follow this convention.
+		if corpusNamePattern.MatchString(file.Name()) {
+			hashes[file.Name()] = path
+			continue
+		}
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, xerrors.Errorf("unable to read corpus entry: %w", err)
+		}
+		hashes[contentHash(raw)] = path
+	}
+	return hashes, nil
+}
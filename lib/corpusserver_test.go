@@ -0,0 +1,110 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib_test
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/oncilla/fuzzinator/lib"
+)
+
+func TestCorpusServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fuzzinator-corpus-server")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	srv := httptest.NewServer(&lib.CorpusServer{Dir: filepath.Join(dir, "server")})
+	defer srv.Close()
+	hash := func(content string) string {
+		sum := sha1.Sum([]byte(content))
+		return hex.EncodeToString(sum[:])
+	}
+	write := func(dir, name, content string) {
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	// Machine a has a seeded entry and a crasher.
+	a := filepath.Join(dir, "a")
+	write(lib.CorpusDir(a), "seed.json", `{"A": 1}`)
+	crasher := hash("crash")
+	write(lib.CrashersDir(a), crasher, "crash")
+	write(lib.CrashersDir(a), crasher+".quoted", `"crash"`)
+	write(lib.CrashersDir(a), crasher+".output", "panic: boom\n")
+	// Machine b has an entry named by go-fuzz.
+	b := filepath.Join(dir, "b")
+	write(lib.CorpusDir(b), hash(`{"A": 2}`), `{"A": 2}`)
+
+	clientA := &lib.CorpusClient{Addr: srv.URL, Target: "fuzz"}
+	stats, err := clientA.Sync(a)
+	require.NoError(t, err)
+	assert.Equal(t, lib.SyncStats{Uploaded: 1, UploadedCrashers: 1}, stats)
+
+	clientB := &lib.CorpusClient{Addr: strings.TrimPrefix(srv.URL, "http://"), Target: "fuzz"}
+	stats, err = clientB.Sync(b)
+	require.NoError(t, err)
+	assert.Equal(t, lib.SyncStats{Uploaded: 1, Pulled: 1}, stats)
+	raw, err := ioutil.ReadFile(filepath.Join(lib.CorpusDir(b), hash(`{"A": 1}`)))
+	require.NoError(t, err)
+	assert.Equal(t, `{"A": 1}`, string(raw))
+
+	stats, err = clientA.Sync(a)
+	require.NoError(t, err)
+	assert.Equal(t, lib.SyncStats{Pulled: 1}, stats)
+	stats, err = clientA.Sync(a)
+	require.NoError(t, err)
+	assert.Equal(t, lib.SyncStats{}, stats)
+
+	crashers, err := clientB.List(lib.KindCrashers)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{
+		crasher: true, crasher + ".quoted": true, crasher + ".output": true,
+	}, crashers)
+
+	// Other targets do not share the corpus.
+	other := &lib.CorpusClient{Addr: srv.URL, Target: "other"}
+	corpus, err := other.List(lib.KindCorpus)
+	require.NoError(t, err)
+	assert.Empty(t, corpus)
+
+	// Entries must match their hash, and names must be valid.
+	assert.Error(t, clientA.Put(lib.KindCorpus, hash("a"), []byte("b")))
+	assert.Error(t, clientA.Put(lib.KindCorpus, "seed.json", []byte("b")))
+	assert.Error(t, (&lib.CorpusClient{Addr: srv.URL, Target: ".."}).Put(
+		lib.KindCorpus, hash("a"), []byte("a")))
+	resp, err := http.Get(srv.URL + "/v1/fuzz/other/")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,496 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"golang.org/x/tools/cover"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/xerrors"
+
+	"github.com/oncilla/fuzzinator/conf"
+)
+
+// CoverConfig configures a coverage run of a corpus through the harness.
+type CoverConfig struct {
+	Harness conf.Harness
+	// Corpus is the directory that contains the corpus inputs.
+	Corpus string
+	// Profile is the path of the coverprofile that is written.
+	Profile string
+	// CoverPkgs are the package patterns coverage is recorded for. If empty,
+	// only the harness package is covered.
+	CoverPkgs []string
+	// Dir is the directory the go commands run in. If empty, the current
+	// directory is used.
+	Dir string
+}
+
+// coverTestName is the name of the generated test that replays the corpus.
+const coverTestName = "TestFuzzinatorCover"
+
+var coverTestTmpl = template.Must(template.New("").Parse(`package {{.Package}}
+
+import (
+	fuzzinatorioutil "io/ioutil"
+	fuzzinatoros "os"
+	fuzzinatorfilepath "path/filepath"
+	fuzzinatortesting "testing"
+)
+
+func ` + coverTestName + `(t *fuzzinatortesting.T) {
+	dir := fuzzinatoros.Getenv("FUZZINATOR_CORPUS")
+	files, err := fuzzinatorioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		data, err := fuzzinatorioutil.ReadFile(fuzzinatorfilepath.Join(dir, file.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		func() {
+			// Crashing inputs must not abort the replay.
+			defer func() { recover() }()
+			{{.Function}}(data)
+		}()
+	}
+}
+`))
+
+// RunCoverage replays the corpus through the harness in a coverage enabled
+// test binary and writes the coverprofile. The test that replays the corpus
+// is added to the harness package with an overlay, the source tree is not
+// modified.
+func RunCoverage(cfg CoverConfig) error {
+	pkgs, err := packages.Load(&packages.Config{
+		Mode:       packages.NeedName | packages.NeedFiles,
+		BuildFlags: []string{"-tags", BuildTags(cfg.Harness)},
+		Dir:        cfg.Dir,
+	}, cfg.Harness.Package)
+	if err != nil {
+		return xerrors.Errorf("unable to load package: %w", err)
+	}
+	if len(pkgs) != 1 || len(pkgs[0].GoFiles) == 0 {
+		return xerrors.Errorf("%q does not resolve to a single package", cfg.Harness.Package)
+	}
+	pkg := pkgs[0]
+	tmp, err := ioutil.TempDir("", "fuzzinator-cover")
+	if err != nil {
+		return xerrors.Errorf("unable to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	var src bytes.Buffer
+	err = coverTestTmpl.Execute(&src, map[string]string{
+		"Package":  pkg.Name,
+		"Function": cfg.Harness.Function,
+	})
+	if err != nil {
+		return xerrors.Errorf("unable to generate replay test: %w", err)
+	}
+	testFile := filepath.Join(tmp, "fuzzinator_cover_test.go")
+	if err := ioutil.WriteFile(testFile, src.Bytes(), 0644); err != nil {
+		return xerrors.Errorf("unable to write replay test: %w", err)
+	}
+	overlay, err := json.Marshal(map[string]map[string]string{
+		"Replace": {
+			filepath.Join(filepath.Dir(pkg.GoFiles[0]), "fuzzinator_cover_test.go"): testFile,
+		},
+	})
+	if err != nil {
+		return xerrors.Errorf("unable to encode overlay: %w", err)
+	}
+	overlayFile := filepath.Join(tmp, "overlay.json")
+	if err := ioutil.WriteFile(overlayFile, overlay, 0644); err != nil {
+		return xerrors.Errorf("unable to write overlay: %w", err)
+	}
+	coverPkgs := cfg.CoverPkgs
+	if len(coverPkgs) == 0 {
+		coverPkgs = []string{pkg.PkgPath}
+	}
+	profile, err := filepath.Abs(cfg.Profile)
+	if err != nil {
+		return xerrors.Errorf("unable to resolve profile path: %w", err)
+	}
+	corpus, err := filepath.Abs(cfg.Corpus)
+	if err != nil {
+		return xerrors.Errorf("unable to resolve corpus path: %w", err)
+	}
+	cmd := exec.Command("go", "test", "-tags", BuildTags(cfg.Harness),
+		"-overlay", overlayFile, "-run", "^"+coverTestName+"$", "-count=1",
+		"-coverprofile", profile, "-coverpkg", strings.Join(coverPkgs, ","), pkg.PkgPath)
+	cmd.Dir = cfg.Dir
+	cmd.Env = append(append(os.Environ(), cfg.Harness.Build.Environ()...),
+		"FUZZINATOR_CORPUS="+corpus)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return xerrors.Errorf("coverage run failed: %w\n%s", err, out)
+	}
+	return nil
+}
+
+// CoverHTML writes the HTML report of the coverprofile.
+func CoverHTML(profile, out, dir string) error {
+	cmd := exec.Command("go", "tool", "cover", "-html", profile, "-o", out)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return xerrors.Errorf("unable to generate HTML report: %w\n%s", err, output)
+	}
+	return nil
+}
+
+// FuncCover is the coverage of a single function.
+type FuncCover struct {
+	// Package is the import path of the package.
+	Package string
+	// Name is the function name, prefixed with the receiver type for methods,
+	// e.g., "(*T).Method".
+	Name       string
+	File       string
+	Line       int
+	Statements int
+	Covered    int
+}
+
+// ID returns the package qualified function name.
+func (f FuncCover) ID() string {
+	return f.Package + "." + f.Name
+}
+
+// Reached indicates whether any statement of the function was executed.
+func (f FuncCover) Reached() bool {
+	return f.Covered > 0
+}
+
+func (f FuncCover) String() string {
+	return fmt.Sprintf("%s:%d: %s (%d/%d statements)", f.File, f.Line, f.ID(),
+		f.Covered, f.Statements)
+}
+
+// FuncCoverage returns the per-function coverage of the coverprofile, sorted
+// by file and line. The source files are resolved relative to dir with the
+// build tags of the harness.
+func FuncCoverage(profile string, harness conf.Harness, dir string) ([]FuncCover, error) {
+	profiles, err := cover.ParseProfiles(profile)
+	if err != nil {
+		return nil, xerrors.Errorf("unable to parse coverprofile: %w", err)
+	}
+	files, err := profileFiles(profiles, harness, dir)
+	if err != nil {
+		return nil, err
+	}
+	var funcs []FuncCover
+	for _, p := range profiles {
+		file, ok := files[p.FileName]
+		if !ok {
+			return nil, xerrors.Errorf("unable to find source file of %s", p.FileName)
+		}
+		fileFuncs, err := funcCoverage(p, file)
+		if err != nil {
+			return nil, err
+		}
+		funcs = append(funcs, fileFuncs...)
+	}
+	sort.Slice(funcs, func(i, j int) bool {
+		if funcs[i].File != funcs[j].File {
+			return funcs[i].File < funcs[j].File
+		}
+		return funcs[i].Line < funcs[j].Line
+	})
+	return funcs, nil
+}
+
+// profileFiles maps the file names in the profiles, i.e., import path and base
+// name, to the source files.
+func profileFiles(profiles []*cover.Profile, harness conf.Harness,
+	dir string) (map[string]string, error) {
+
+	pkgSet := make(map[string]bool)
+	for _, p := range profiles {
+		pkgSet[filepath.ToSlash(filepath.Dir(p.FileName))] = true
+	}
+	var patterns []string
+	for pkg := range pkgSet {
+		patterns = append(patterns, pkg)
+	}
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	pkgs, err := packages.Load(&packages.Config{
+		Mode:       packages.NeedName | packages.NeedFiles,
+		BuildFlags: []string{"-tags", BuildTags(harness)},
+		Dir:        dir,
+	}, patterns...)
+	if err != nil {
+		return nil, xerrors.Errorf("unable to load covered packages: %w", err)
+	}
+	files := make(map[string]string)
+	for _, pkg := range pkgs {
+		for _, file := range pkg.CompiledGoFiles {
+			files[pkg.PkgPath+"/"+filepath.Base(file)] = file
+		}
+		for _, file := range pkg.GoFiles {
+			files[pkg.PkgPath+"/"+filepath.Base(file)] = file
+		}
+	}
+	return files, nil
+}
+
+func funcCoverage(p *cover.Profile, file string) ([]FuncCover, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, nil, 0)
+	if err != nil {
+		return nil, xerrors.Errorf("unable to parse %s: %w", file, err)
+	}
+	pkg := filepath.ToSlash(filepath.Dir(p.FileName))
+	var funcs []FuncCover
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+		start, end := fset.Position(fn.Pos()), fset.Position(fn.End())
+		fc := FuncCover{
+			Package: pkg,
+			Name:    funcName(fn),
+			File:    file,
+			Line:    start.Line,
+		}
+		for _, b := range p.Blocks {
+			if b.StartLine > end.Line || (b.StartLine == end.Line && b.StartCol >= end.Column) {
+				continue
+			}
+			if b.EndLine < start.Line || (b.EndLine == start.Line && b.EndCol <= start.Column) {
+				continue
+			}
+			fc.Statements += b.NumStmt
+			if b.Count > 0 {
+				fc.Covered += b.NumStmt
+			}
+		}
+		funcs = append(funcs, fc)
+	}
+	return funcs, nil
+}
+
+// funcName returns the function name, prefixed with the receiver type for
+// methods.
+func funcName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+	typ := fn.Recv.List[0].Type
+	var ptr string
+	if star, ok := typ.(*ast.StarExpr); ok {
+		ptr, typ = "*", star.X
+	}
+	// Strip type parameters of generic receivers.
+	switch t := typ.(type) {
+	case *ast.IndexExpr:
+		typ = t.X
+	case *ast.IndexListExpr:
+		typ = t.X
+	}
+	if ident, ok := typ.(*ast.Ident); ok {
+		return fmt.Sprintf("(%s%s).%s", ptr, ident.Name, fn.Name.Name)
+	}
+	return fn.Name.Name
+}
+
+// CoverChange is the change in coverage of a single function between two
+// coverage runs.
+type CoverChange struct {
+	ID string
+	// Before is the coverage of the function in the first run. It is nil if
+	// the function does not exist in the first run.
+	Before *FuncCover
+	// After is the coverage of the function in the second run. It is nil if
+	// the function does not exist in the second run.
+	After *FuncCover
+}
+
+// Delta returns the change in covered statements.
+func (c CoverChange) Delta() int {
+	var before, after int
+	if c.Before != nil {
+		before = c.Before.Covered
+	}
+	if c.After != nil {
+		after = c.After.Covered
+	}
+	return after - before
+}
+
+func (c CoverChange) String() string {
+	cov := func(f *FuncCover) string {
+		if f == nil {
+			return "absent"
+		}
+		return fmt.Sprintf("%d/%d", f.Covered, f.Statements)
+	}
+	return fmt.Sprintf("%s: %s -> %s", c.ID, cov(c.Before), cov(c.After))
+}
+
+// DiffCoverage returns the functions whose covered statements differ between
+// the two coverage runs, sorted by function. Functions are matched by their
+// package qualified name.
+func DiffCoverage(before, after []FuncCover) []CoverChange {
+	changes := make(map[string]*CoverChange)
+	change := func(id string) *CoverChange {
+		if c, ok := changes[id]; ok {
+			return c
+		}
+		c := &CoverChange{ID: id}
+		changes[id] = c
+		return c
+	}
+	for i := range before {
+		change(before[i].ID()).Before = &before[i]
+	}
+	for i := range after {
+		change(after[i].ID()).After = &after[i]
+	}
+	var diff []CoverChange
+	for _, c := range changes {
+		if c.Delta() != 0 {
+			diff = append(diff, *c)
+		}
+	}
+	sort.Slice(diff, func(i, j int) bool { return diff[i].ID < diff[j].ID })
+	return diff
+}
+
+// ReachablePackages returns the import paths of the packages that are
+// transitively imported by the harness package, including the harness package
+// itself, and whose sources are located in root. The packages are resolved
+// relative to dir.
+func ReachablePackages(harness conf.Harness, dir, root string) ([]string, error) {
+	pkgs, err := packages.Load(&packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedImports |
+			packages.NeedDeps,
+		BuildFlags: []string{"-tags", BuildTags(harness)},
+		Dir:        dir,
+	}, harness.Package)
+	if err != nil {
+		return nil, xerrors.Errorf("unable to load package: %w", err)
+	}
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return nil, xerrors.Errorf("unable to resolve root: %w", err)
+	}
+	var reachable []string
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if len(pkg.GoFiles) == 0 {
+			return
+		}
+		rel, err := filepath.Rel(root, pkg.GoFiles[0])
+		if err != nil || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return
+		}
+		reachable = append(reachable, pkg.PkgPath)
+	})
+	sort.Strings(reachable)
+	return reachable, nil
+}
+
+// LineCoverage returns the executable lines of the coverprofile indexed by the
+// absolute path of the source file. A line is marked as covered if any
+// statement on it was executed. The source files are resolved relative to
+// dir with the build tags of the harness.
+func LineCoverage(profile string, harness conf.Harness,
+	dir string) (map[string]map[int]bool, error) {
+
+	profiles, err := cover.ParseProfiles(profile)
+	if err != nil {
+		return nil, xerrors.Errorf("unable to parse coverprofile: %w", err)
+	}
+	files, err := profileFiles(profiles, harness, dir)
+	if err != nil {
+		return nil, err
+	}
+	lines := make(map[string]map[int]bool)
+	for _, p := range profiles {
+		file, ok := files[p.FileName]
+		if !ok {
+			return nil, xerrors.Errorf("unable to find source file of %s", p.FileName)
+		}
+		raw, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, xerrors.Errorf("unable to read source file: %w", err)
+		}
+		src := strings.Split(string(raw), "\n")
+		if lines[file] == nil {
+			lines[file] = make(map[int]bool)
+		}
+		for _, b := range p.Blocks {
+			start, end := b.StartLine, b.EndLine
+			// Blocks may start after the opening and end at the closing brace.
+			// Lines that only contain the brace are not marked.
+			if start < end && start <= len(src) &&
+				strings.TrimSpace(columns(src[start-1], b.StartCol, -1)) == "" {
+				start++
+			}
+			if start < end && end <= len(src) {
+				rest := strings.TrimSpace(columns(src[end-1], 1, b.EndCol-1))
+				if rest == "" || rest == "}" {
+					end--
+				}
+			}
+			for l := start; l <= end; l++ {
+				lines[file][l] = lines[file][l] || b.Count > 0
+			}
+		}
+	}
+	return lines, nil
+}
+
+// columns returns the part of the line between the 1-based byte columns, both
+// inclusive. A negative end selects the rest of the line.
+func columns(line string, start, end int) string {
+	if end < 0 || end > len(line) {
+		end = len(line)
+	}
+	if start < 1 {
+		start = 1
+	}
+	if start > end {
+		return ""
+	}
+	return line[start-1 : end]
+}
@@ -0,0 +1,113 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/oncilla/fuzzinator/conf"
+	"github.com/oncilla/fuzzinator/lib"
+)
+
+func TestCoverage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fuzzinator-cover")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	harness := conf.Harness{
+		Package:  "github.com/oncilla/fuzzinator/test",
+		Function: "Fuzz",
+	}
+	profile := filepath.Join(dir, "cover.out")
+	err = lib.RunCoverage(lib.CoverConfig{
+		Harness: harness,
+		Corpus:  "../test/corpus",
+		Profile: profile,
+	})
+	require.NoError(t, err)
+
+	funcs, err := lib.FuncCoverage(profile, harness, "")
+	require.NoError(t, err)
+	require.Len(t, funcs, 1)
+	assert.Equal(t, "github.com/oncilla/fuzzinator/test.Fuzz", funcs[0].ID())
+	assert.True(t, funcs[0].Reached())
+	assert.True(t, funcs[0].Covered < funcs[0].Statements)
+
+	lines, err := lib.LineCoverage(profile, harness, "")
+	require.NoError(t, err)
+	require.Len(t, lines, 1)
+	src, err := filepath.Abs("../test/fuzz.go")
+	require.NoError(t, err)
+	raw, err := ioutil.ReadFile(src)
+	require.NoError(t, err)
+	for i, line := range strings.Split(string(raw), "\n") {
+		switch strings.TrimSpace(line) {
+		case "panic(a.A)":
+			assert.Equal(t, false, lines[src][i+1], line)
+		case "return 1":
+			assert.Equal(t, true, lines[src][i+1], line)
+		}
+	}
+
+	html := filepath.Join(dir, "cover.html")
+	require.NoError(t, lib.CoverHTML(profile, html, ""))
+	assert.FileExists(t, html)
+}
+
+func TestDiffCoverage(t *testing.T) {
+	before := []lib.FuncCover{
+		{Package: "p", Name: "Same", Statements: 4, Covered: 2},
+		{Package: "p", Name: "Lost", Statements: 4, Covered: 3},
+		{Package: "p", Name: "Removed", Statements: 2, Covered: 2},
+		{Package: "p", Name: "(*T).Gained", Statements: 4, Covered: 0},
+	}
+	after := []lib.FuncCover{
+		{Package: "p", Name: "Same", Statements: 5, Covered: 2},
+		{Package: "p", Name: "Lost", Statements: 4, Covered: 1},
+		{Package: "p", Name: "(*T).Gained", Statements: 4, Covered: 4},
+		{Package: "p", Name: "Added", Statements: 3, Covered: 1},
+		{Package: "p", Name: "Unreached", Statements: 3, Covered: 0},
+	}
+	diff := lib.DiffCoverage(before, after)
+	require.Len(t, diff, 4)
+	deltas := make(map[string]int)
+	for _, c := range diff {
+		deltas[c.ID] = c.Delta()
+	}
+	assert.Equal(t, map[string]int{
+		"p.(*T).Gained": 4,
+		"p.Added":       1,
+		"p.Lost":        -2,
+		"p.Removed":     -2,
+	}, deltas)
+	assert.Equal(t, "p.(*T).Gained", diff[0].ID)
+	assert.Nil(t, diff[1].Before)
+	assert.Equal(t, "p.Added: absent -> 1/3", diff[1].String())
+}
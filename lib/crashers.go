@@ -0,0 +1,316 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/oncilla/fuzzinator/conf"
+)
+
+// Crasher is a crashing input found by go-fuzz. It consists of the input
+// file, and the .quoted and .output files in the crashers directory.
+type Crasher struct {
+	// Name is the name of the input, i.e., the SHA1 hash of its content.
+	Name string `json:"name"`
+	// Signature identifies the crash independently of the input, see
+	// CrashSignature.
+	Signature string `json:"signature"`
+	// Title is the first line of the crash output, e.g., the panic message.
+	Title string `json:"title"`
+	// Stack contains the functions on the stack of the crashing goroutine.
+	Stack []string `json:"stack"`
+}
+
+// Suppressed returns the first suppression that matches the crasher.
+func (c Crasher) Suppressed(suppressions []conf.Suppression) (conf.Suppression, bool) {
+	for _, s := range suppressions {
+		if s.Matches(c.Signature, c.Title, c.Stack) {
+			return s, true
+		}
+	}
+	return conf.Suppression{}, false
+}
+
+// crasherFiles are the suffixes of the files go-fuzz writes per crasher.
+var crasherFiles = []string{"", ".quoted", ".output"}
+
+var (
+	hexPattern = regexp.MustCompile(`0x[0-9a-fA-F]+`)
+	numPattern = regexp.MustCompile(`[0-9]+`)
+)
+
+// CrashSignature returns the signature of the crash output. It is derived from
+// the first line of the output and the functions on the stack of the crashing
+// goroutine. Addresses and numbers are masked, such that crashes with the same
+// cause share the signature.
+func CrashSignature(output []byte) string {
+	title, stack := parseCrashOutput(output)
+	parts := append([]string{numPattern.ReplaceAllString(
+		hexPattern.ReplaceAllString(title, "?"), "?")}, stack...)
+	return contentHash([]byte(strings.Join(parts, "\n")))[:12]
+}
+
+// parseCrashOutput returns the first line of the crash output, and the
+// functions on the stack of the crashing goroutine.
+func parseCrashOutput(output []byte) (string, []string) {
+	lines := strings.Split(string(output), "\n")
+	var title string
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			title, lines = line, lines[i+1:]
+			break
+		}
+	}
+	// The first goroutine in the trace is the crashing one.
+	var stack []string
+	var inStack bool
+	for _, line := range lines {
+		if strings.HasPrefix(line, "goroutine ") {
+			if inStack {
+				break
+			}
+			inStack = true
+			continue
+		}
+		if !inStack || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		if line == "" {
+			break
+		}
+		// Function lines contain the arguments, e.g., "pkg.Fuzz(0x1, 0x2)".
+		if i := strings.LastIndex(line, "("); i > 0 {
+			line = line[:i]
+		}
+		stack = append(stack, line)
+	}
+	return title, stack
+}
+
+// ListCrashers returns the crashers in the workdir sorted by name.
+func ListCrashers(workdir string) ([]Crasher, error) {
+	files, err := ioutil.ReadDir(CrashersDir(workdir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("unable to list crashers: %w", err)
+	}
+	var crashers []Crasher
+	for _, file := range files {
+		if !file.Mode().IsRegular() || filepath.Ext(file.Name()) != "" {
+			continue
+		}
+		output, err := ioutil.ReadFile(filepath.Join(CrashersDir(workdir), file.Name()+".output"))
+		if os.IsNotExist(err) {
+			// go-fuzz writes the output after the input.
+			continue
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("unable to read crasher output: %w", err)
+		}
+		title, stack := parseCrashOutput(output)
+		crashers = append(crashers, Crasher{
+			Name:      file.Name(),
+			Signature: CrashSignature(output),
+			Title:     title,
+			Stack:     stack,
+		})
+	}
+	return crashers, nil
+}
+
+// LedgerEntry records an exported crasher.
+type LedgerEntry struct {
+	Signature string    `json:"signature"`
+	Exported  time.Time `json:"exported"`
+}
+
+// Ledger records the crashers that were already exported from a workdir,
+// indexed by name.
+type Ledger map[string]LedgerEntry
+
+// LedgerPath returns the path of the crashers ledger in the workdir.
+func LedgerPath(workdir string) string {
+	return filepath.Join(workdir, "crashers.json")
+}
+
+// ReadLedger reads the crashers ledger of the workdir. A missing ledger is
+// empty.
+func ReadLedger(workdir string) (Ledger, error) {
+	ledger := make(Ledger)
+	raw, err := ioutil.ReadFile(LedgerPath(workdir))
+	if os.IsNotExist(err) {
+		return ledger, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("unable to read crashers ledger: %w", err)
+	}
+	if err := json.Unmarshal(raw, &ledger); err != nil {
+		return nil, xerrors.Errorf("unable to decode crashers ledger: %w", err)
+	}
+	return ledger, nil
+}
+
+// WriteLedger writes the crashers ledger of the workdir.
+func WriteLedger(workdir string, ledger Ledger) error {
+	raw, err := json.MarshalIndent(ledger, "", "  ")
+	if err != nil {
+		return xerrors.Errorf("unable to encode crashers ledger: %w", err)
+	}
+	if err := ioutil.WriteFile(LedgerPath(workdir), raw, 0644); err != nil {
+		return xerrors.Errorf("unable to write crashers ledger: %w", err)
+	}
+	return nil
+}
+
+func (l Ledger) hasSignature(signature string) bool {
+	for _, entry := range l {
+		if entry.Signature == signature {
+			return true
+		}
+	}
+	return false
+}
+
+// IgnoreListName is the name of the ignore list in the crashers root.
+const IgnoreListName = "ignored.txt"
+
+// ReadStorageIgnoreList reads the crash signatures that should not be
+// exported from the crashers root storage. Each line starts with a signature,
+// the remainder of the line and lines starting with '#' are comments. A
+// missing file is an empty list.
+func ReadStorageIgnoreList(root Storage) (map[string]bool, error) {
+	raw, err := root.Read(IgnoreListName)
+	if xerrors.Is(err, os.ErrNotExist) {
+		return make(map[string]bool), nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("unable to read ignore list: %w", err)
+	}
+	ignored := make(map[string]bool)
+	for _, line := range strings.Split(string(raw), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		ignored[fields[0]] = true
+	}
+	return ignored, nil
+}
+
+// ExportSummary summarizes the crashers of an export.
+type ExportSummary struct {
+	// New are the exported crashers with a signature that was not exported
+	// before.
+	New []Crasher
+	// Known are the exported crashers with a signature that was already
+	// exported with a different input.
+	Known []Crasher
+	// Ignored are the crashers with an ignored signature.
+	Ignored []Crasher
+	// Suppressed are the crashers that match a suppression.
+	Suppressed []Crasher
+	// Previous is the number of crashers that were exported by previous runs
+	// and are neither ignored nor suppressed by now.
+	Previous int
+}
+
+// Exported returns the number of exported crashers.
+func (s ExportSummary) Exported() int {
+	return len(s.New) + len(s.Known)
+}
+
+// Unsuppressed returns the number of crashers in the workdir that are neither
+// ignored nor suppressed, whether they were exported by this or by previous
+// runs.
+func (s ExportSummary) Unsuppressed() int {
+	return s.Exported() + s.Previous
+}
+
+// ExportCrashers copies the crashers from the workdir to the target storage
+// that have not been exported before. Crashers with an ignored signature or
+// that match a suppression are skipped. Exported crashers are recorded in the
+// ledger of the workdir, such that crashers deleted from the target storage
+// are not exported again.
+func ExportCrashers(workdir string, target Storage, ignored map[string]bool,
+	suppressions []conf.Suppression) (summary ExportSummary, err error) {
+
+	crashers, err := ListCrashers(workdir)
+	if err != nil {
+		return summary, err
+	}
+	ledger, err := ReadLedger(workdir)
+	if err != nil {
+		return summary, err
+	}
+	// The ledger is written even if the export fails part way, such that the
+	// crashers copied so far are not exported again.
+	defer func() {
+		if summary.Exported() == 0 {
+			return
+		}
+		if werr := WriteLedger(workdir, ledger); werr != nil && err == nil {
+			err = werr
+		}
+	}()
+	for _, crasher := range crashers {
+		if ignored[crasher.Signature] {
+			summary.Ignored = append(summary.Ignored, crasher)
+			continue
+		}
+		if _, ok := crasher.Suppressed(suppressions); ok {
+			summary.Suppressed = append(summary.Suppressed, crasher)
+			continue
+		}
+		if ledger[crasher.Name] != (LedgerEntry{}) {
+			summary.Previous++
+			continue
+		}
+		for _, suffix := range crasherFiles {
+			raw, err := ioutil.ReadFile(filepath.Join(CrashersDir(workdir), crasher.Name+suffix))
+			if err != nil {
+				return summary, xerrors.Errorf("unable to read crasher: %w", err)
+			}
+			if err := target.Write(crasher.Name+suffix, raw); err != nil {
+				return summary, xerrors.Errorf("unable to copy crasher: %w", err)
+			}
+		}
+		if ledger.hasSignature(crasher.Signature) {
+			summary.Known = append(summary.Known, crasher)
+		} else {
+			summary.New = append(summary.New, crasher)
+		}
+		ledger[crasher.Name] = LedgerEntry{Signature: crasher.Signature, Exported: time.Now()}
+	}
+	return summary, nil
+}
@@ -0,0 +1,171 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/oncilla/fuzzinator/conf"
+	"github.com/oncilla/fuzzinator/lib"
+)
+
+const indexPanic = `panic: runtime error: index out of range [%s] with length 3
+
+goroutine 1 [running]:
+github.com/oncilla/fuzzinator/test.Fuzz(0x%s, 0x3, 0x3, 0x3)
+	/src/test/fuzz.go:12 +0x1d
+go-fuzz-dep.Main(0xc000012345, 0x1, 0x1)
+	go-fuzz-dep/main.go:36 +0x1b8
+main.main()
+	/tmp/go-fuzz-build/main.go:10 +0x5e
+
+goroutine 6 [chan receive]:
+main.worker()
+`
+
+const nilPanic = `panic: runtime error: invalid memory address or nil pointer dereference
+
+goroutine 1 [running]:
+github.com/oncilla/fuzzinator/test.Fuzz(0x1, 0x3, 0x3, 0x3)
+	/src/test/fuzz.go:18 +0x1d
+`
+
+func TestCrashSignature(t *testing.T) {
+	sig := func(output string) string {
+		return lib.CrashSignature([]byte(output))
+	}
+	a := sig(indexPanicOutput("4", "c000010000"))
+	b := sig(indexPanicOutput("7", "c000020000"))
+	assert.Equal(t, a, b)
+	assert.Len(t, a, 12)
+	assert.NotEqual(t, a, sig(nilPanic))
+}
+
+func TestExportCrashers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fuzzinator-crashers")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	workdir := filepath.Join(dir, "workdir")
+	target := filepath.Join(dir, "crashers")
+	dst := lib.LocalStorage{Dir: target}
+	require.NoError(t, os.MkdirAll(lib.CrashersDir(workdir), 0755))
+	addCrasher := func(name, output string) {
+		for suffix, content := range map[string]string{"": name, ".quoted": name,
+			".output": output} {
+			file := filepath.Join(lib.CrashersDir(workdir), name+suffix)
+			require.NoError(t, ioutil.WriteFile(file, []byte(content), 0644))
+		}
+	}
+	addCrasher("a", indexPanicOutput("4", "1"))
+	addCrasher("b", indexPanicOutput("5", "2"))
+	addCrasher("c", nilPanic)
+	addCrasher("e", strings.Replace(nilPanic, "invalid memory address",
+		"invariant violated", 1))
+	ignored := map[string]bool{lib.CrashSignature([]byte(nilPanic)): true}
+	suppressions := []conf.Suppression{{Panic: "invariant violated"}}
+
+	summary, err := lib.ExportCrashers(workdir, dst, ignored, suppressions)
+	require.NoError(t, err)
+	assert.Equal(t, 2, summary.Exported())
+	assert.Equal(t, 2, summary.Unsuppressed())
+	require.Len(t, summary.New, 1)
+	assert.Equal(t, "a", summary.New[0].Name)
+	assert.Equal(t, "panic: runtime error: index out of range [4] with length 3",
+		summary.New[0].Title)
+	require.Len(t, summary.Known, 1)
+	assert.Equal(t, "b", summary.Known[0].Name)
+	require.Len(t, summary.Ignored, 1)
+	assert.Equal(t, "c", summary.Ignored[0].Name)
+	require.Len(t, summary.Suppressed, 1)
+	assert.Equal(t, "e", summary.Suppressed[0].Name)
+	files, err := ioutil.ReadDir(target)
+	require.NoError(t, err)
+	assert.Len(t, files, 6)
+
+	// Crashers deleted from the target are not exported again.
+	require.NoError(t, os.RemoveAll(target))
+	addCrasher("d", indexPanicOutput("6", "3"))
+	summary, err = lib.ExportCrashers(workdir, dst, ignored, suppressions)
+	require.NoError(t, err)
+	assert.Equal(t, 2, summary.Previous)
+	assert.Empty(t, summary.New)
+	require.Len(t, summary.Known, 1)
+	assert.Equal(t, "d", summary.Known[0].Name)
+	assert.Equal(t, 3, summary.Unsuppressed())
+	files, err = ioutil.ReadDir(target)
+	require.NoError(t, err)
+	assert.Len(t, files, 3)
+
+	// Crashers copied before a failing export are recorded in the ledger.
+	addCrasher("f", indexPanicOutput("7", "4"))
+	addCrasher("g", indexPanicOutput("8", "5"))
+	quoted := filepath.Join(lib.CrashersDir(workdir), "g.quoted")
+	require.NoError(t, os.Remove(quoted))
+	_, err = lib.ExportCrashers(workdir, dst, ignored, suppressions)
+	require.Error(t, err)
+	require.NoError(t, os.RemoveAll(target))
+	require.NoError(t, ioutil.WriteFile(quoted, []byte("g"), 0644))
+	summary, err = lib.ExportCrashers(workdir, dst, ignored, suppressions)
+	require.NoError(t, err)
+	assert.Equal(t, 4, summary.Previous)
+	require.Len(t, summary.Known, 1)
+	assert.Equal(t, "g", summary.Known[0].Name)
+
+	// Previously exported crashers that are suppressed by now do not count.
+	suppressions = append(suppressions, conf.Suppression{Panic: "index out of range"})
+	summary, err = lib.ExportCrashers(workdir, dst, ignored, suppressions)
+	require.NoError(t, err)
+	assert.Equal(t, 0, summary.Previous)
+	assert.Len(t, summary.Suppressed, 6)
+	assert.Equal(t, 0, summary.Unsuppressed())
+}
+
+func TestReadStorageIgnoreList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fuzzinator-ignore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	root := lib.LocalStorage{Dir: dir}
+	ignored, err := lib.ReadStorageIgnoreList(root)
+	require.NoError(t, err)
+	assert.Empty(t, ignored)
+
+	require.NoError(t, root.Write(lib.IgnoreListName,
+		[]byte("# won't fix\n0123456789ab panic: known\n\n  ba9876543210\n")))
+	ignored, err = lib.ReadStorageIgnoreList(root)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"0123456789ab": true, "ba9876543210": true}, ignored)
+}
+
+func indexPanicOutput(index, addr string) string {
+	return fmt.Sprintf(indexPanic, index, addr)
+}
@@ -0,0 +1,133 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib
+
+import (
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/xerrors"
+)
+
+// FuzzFunc is a fuzzing entry point discovered in a package.
+type FuzzFunc struct {
+	// Package is the import path of the package.
+	Package string
+	// Name is the package name.
+	Name string
+	// Dir is the directory of the package.
+	Dir string
+	// Function is the name of the entry point.
+	Function string
+	// Native indicates a native go fuzzing entry point func FuzzXxx(*testing.F)
+	// declared in a test file. These cannot be built by go-fuzz.
+	Native bool
+}
+
+// DiscoverFuzzFuncs scans the packages matching the patterns for go-fuzz entry
+// points func Fuzz*([]byte) int, and native go fuzzing entry points
+// func FuzzXxx(*testing.F). The packages are loaded with the 'gofuzz' build
+// tag. The result is sorted by package and function.
+func DiscoverFuzzFuncs(patterns ...string) ([]FuzzFunc, error) {
+	cfg := &packages.Config{
+		Mode:       packages.NeedName | packages.NeedFiles | packages.NeedTypes,
+		BuildFlags: []string{"-tags", "gofuzz"},
+		Tests:      true,
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, xerrors.Errorf("unable to load packages: %w", err)
+	}
+	seen := make(map[FuzzFunc]bool)
+	var funcs []FuzzFunc
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			return nil, xerrors.Errorf("unable to load %q: %s", pkg.PkgPath, pkg.Errors[0])
+		}
+		if pkg.Types == nil || len(pkg.GoFiles) == 0 {
+			continue
+		}
+		// Test variants contain all functions of the package itself. Only
+		// consider native entry points in them.
+		isTest := pkg.ID != pkg.PkgPath
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			fn, ok := scope.Lookup(name).(*types.Func)
+			if !ok || !strings.HasPrefix(name, "Fuzz") {
+				continue
+			}
+			sig := fn.Type().(*types.Signature)
+			f := FuzzFunc{
+				Package:  strings.TrimSuffix(pkg.PkgPath, "_test"),
+				Name:     pkg.Name,
+				Dir:      filepath.Dir(pkg.GoFiles[0]),
+				Function: name,
+			}
+			switch {
+			case !isTest && isFuzzFunc(sig):
+			case isTest && isNativeFuzzFunc(name, sig):
+				f.Native = true
+			default:
+				continue
+			}
+			if !seen[f] {
+				seen[f] = true
+				funcs = append(funcs, f)
+			}
+		}
+	}
+	sort.Slice(funcs, func(i, j int) bool {
+		if funcs[i].Package != funcs[j].Package {
+			return funcs[i].Package < funcs[j].Package
+		}
+		return funcs[i].Function < funcs[j].Function
+	})
+	return funcs, nil
+}
+
+// isNativeFuzzFunc checks whether the function is a native go fuzzing entry
+// point func FuzzXxx(*testing.F).
+func isNativeFuzzFunc(name string, sig *types.Signature) bool {
+	if suffix := strings.TrimPrefix(name, "Fuzz"); suffix != "" {
+		if r, _ := utf8.DecodeRuneInString(suffix); unicode.IsLower(r) {
+			return false
+		}
+	}
+	if sig.Recv() != nil || sig.Params().Len() != 1 || sig.Results().Len() != 0 {
+		return false
+	}
+	ptr, ok := sig.Params().At(0).Type().(*types.Pointer)
+	if !ok {
+		return false
+	}
+	named, ok := ptr.Elem().(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return false
+	}
+	return named.Obj().Pkg().Path() == "testing" && named.Obj().Name() == "F"
+}
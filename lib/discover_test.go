@@ -0,0 +1,52 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/oncilla/fuzzinator/lib"
+)
+
+func TestDiscoverFuzzFuncs(t *testing.T) {
+	funcs, err := lib.DiscoverFuzzFuncs("../test", "./testdata/discover")
+	require.NoError(t, err)
+	var found []string
+	for _, f := range funcs {
+		found = append(found, f.Package+"."+f.Function)
+		if f.Function == "FuzzNative" {
+			assert.True(t, f.Native)
+		} else {
+			assert.False(t, f.Native, f.Function)
+		}
+	}
+	assert.Equal(t, []string{
+		"github.com/oncilla/fuzzinator/lib/testdata/discover.FuzzInput",
+		"github.com/oncilla/fuzzinator/lib/testdata/discover.FuzzNative",
+		"github.com/oncilla/fuzzinator/test.Fuzz",
+	}, found)
+	assert.Equal(t, "test", funcs[2].Name)
+}
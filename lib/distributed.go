@@ -0,0 +1,177 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// Paths served by the coordinator to the workers.
+const (
+	CoordinatorInfoPath   = "/info"
+	CoordinatorBinaryPath = "/fuzz.zip"
+)
+
+// coordinatorClient is used by the workers to fetch the info and the fuzzing
+// binary from the coordinator.
+var coordinatorClient = &http.Client{Timeout: 5 * time.Minute}
+
+// CoordinatorInfo describes the fuzzing coordinator to its workers.
+type CoordinatorInfo struct {
+	Target string `json:"target"`
+	Commit string `json:"commit"`
+	// BuildKey is the build key of the fuzzing binary.
+	BuildKey string `json:"build_key"`
+	// BinarySHA256 is the hex encoded SHA256 hash of the fuzzing binary.
+	BinarySHA256 string `json:"binary_sha256"`
+	// Coordinator is the address go-fuzz coordinates the workers on. If the
+	// host is unspecified, the workers use the host of the coordinator.
+	Coordinator string `json:"coordinator"`
+	// Args are the go-fuzz arguments for the workers.
+	Args []string `json:"args"`
+}
+
+// CoordinatorHandler serves the info and the fuzzing binary to the workers.
+func CoordinatorHandler(info CoordinatorInfo, bin string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(CoordinatorInfoPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(info)
+	})
+	mux.HandleFunc(CoordinatorBinaryPath, func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, bin)
+	})
+	return mux
+}
+
+// FetchCoordinatorInfo fetches the info from the coordinator at the address.
+// The go-fuzz coordinator address is resolved relative to the address, if its
+// host is unspecified.
+func FetchCoordinatorInfo(addr string) (CoordinatorInfo, error) {
+	var info CoordinatorInfo
+	resp, err := coordinatorGet(addr, CoordinatorInfoPath)
+	if err != nil {
+		return info, err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return info, xerrors.Errorf("unable to decode coordinator info: %w", err)
+	}
+	coordinator, err := resolveCoordinator(info.Coordinator, coordinatorURL(addr, ""))
+	if err != nil {
+		return info, err
+	}
+	info.Coordinator = coordinator
+	return info, nil
+}
+
+// FetchBinary downloads the fuzzing binary from the coordinator at the address
+// to the file, and verifies its hash against the info.
+func FetchBinary(addr string, info CoordinatorInfo, file string) error {
+	resp, err := coordinatorGet(addr, CoordinatorBinaryPath)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	f, err := os.Create(file)
+	if err != nil {
+		return xerrors.Errorf("unable to create fuzzing binary: %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), resp.Body); err != nil {
+		return xerrors.Errorf("unable to download fuzzing binary: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return xerrors.Errorf("unable to write fuzzing binary: %w", err)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != info.BinarySHA256 {
+		return xerrors.Errorf("fuzzing binary hash mismatch: expected %s, got %s",
+			info.BinarySHA256, sum)
+	}
+	return nil
+}
+
+// FileSHA256 returns the hex encoded SHA256 hash of the file.
+func FileSHA256(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", xerrors.Errorf("unable to open file: %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", xerrors.Errorf("unable to hash file: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func coordinatorGet(addr, path string) (*http.Response, error) {
+	u := coordinatorURL(addr, path)
+	resp, err := coordinatorClient.Get(u)
+	if err != nil {
+		return nil, xerrors.Errorf("unable to reach coordinator: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, xerrors.Errorf("request to %s failed: %s", u, resp.Status)
+	}
+	return resp, nil
+}
+
+// coordinatorURL returns the URL of the path on the coordinator. The address
+// is either a host:port pair, or an http(s) URL.
+func coordinatorURL(addr, path string) string {
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	return strings.TrimSuffix(addr, "/") + path
+}
+
+// resolveCoordinator replaces an unspecified host in the go-fuzz coordinator
+// address with the host of the coordinator URL.
+func resolveCoordinator(coordinator, coordinatorURL string) (string, error) {
+	host, port, err := net.SplitHostPort(coordinator)
+	if err != nil {
+		return "", xerrors.Errorf("invalid coordinator address %q: %w", coordinator, err)
+	}
+	if ip := net.ParseIP(host); host != "" && (ip == nil || !ip.IsUnspecified()) {
+		return coordinator, nil
+	}
+	u, err := url.Parse(coordinatorURL)
+	if err != nil {
+		return "", xerrors.Errorf("invalid coordinator URL %q: %w", coordinatorURL, err)
+	}
+	return net.JoinHostPort(u.Hostname(), port), nil
+}
@@ -0,0 +1,80 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib_test
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/oncilla/fuzzinator/lib"
+)
+
+func TestCoordinator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fuzzinator-coordinator")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bin := filepath.Join(dir, "fuzz.zip")
+	require.NoError(t, ioutil.WriteFile(bin, []byte("binary"), 0644))
+	sum, err := lib.FileSHA256(bin)
+	require.NoError(t, err)
+	info := lib.CoordinatorInfo{
+		Target:       "fuzz",
+		Commit:       "0123456789abcdef0123456789abcdef01234567",
+		BinarySHA256: sum,
+		Coordinator:  ":8745",
+		Args:         []string{"-timeout=10"},
+	}
+	srv := httptest.NewServer(lib.CoordinatorHandler(info, bin))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	fetched, err := lib.FetchCoordinatorInfo(u.Host)
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1:8745", fetched.Coordinator)
+	fetched.Coordinator = info.Coordinator
+	assert.Equal(t, info, fetched)
+
+	dst := filepath.Join(dir, "worker.zip")
+	require.NoError(t, lib.FetchBinary(srv.URL, fetched, dst))
+	raw, err := ioutil.ReadFile(dst)
+	require.NoError(t, err)
+	assert.Equal(t, "binary", string(raw))
+
+	fetched.BinarySHA256 = "other"
+	assert.Error(t, lib.FetchBinary(srv.URL, fetched, dst))
+
+	info.Coordinator = "10.0.0.1:8745"
+	srv.Config.Handler = lib.CoordinatorHandler(info, bin)
+	fetched, err = lib.FetchCoordinatorInfo(srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1:8745", fetched.Coordinator)
+}
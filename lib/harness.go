@@ -0,0 +1,94 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib
+
+import (
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/xerrors"
+
+	"github.com/oncilla/fuzzinator/conf"
+)
+
+// BuildTags returns the build tags used for the harness, including the
+// 'gofuzz' build tag.
+func BuildTags(harness conf.Harness) string {
+	tags := []string{"gofuzz"}
+	for _, tag := range strings.FieldsFunc(harness.BuildTags, isTagSep) {
+		if tag != "gofuzz" {
+			tags = append(tags, tag)
+		}
+	}
+	return strings.Join(tags, ",")
+}
+
+func isTagSep(r rune) bool {
+	return r == ',' || r == ' '
+}
+
+// CheckHarness verifies that the harness function exists and has the signature
+// func([]byte) int expected by go-fuzz.
+func CheckHarness(harness conf.Harness) error {
+	cfg := &packages.Config{
+		Mode:       packages.NeedName | packages.NeedTypes,
+		BuildFlags: []string{"-tags", BuildTags(harness)},
+	}
+	pkgs, err := packages.Load(cfg, harness.Package)
+	if err != nil {
+		return xerrors.Errorf("unable to load package: %w", err)
+	}
+	if len(pkgs) != 1 {
+		return xerrors.Errorf("%q resolved to %d packages", harness.Package, len(pkgs))
+	}
+	pkg := pkgs[0]
+	if len(pkg.Errors) > 0 {
+		return xerrors.Errorf("unable to type check %q: %s", harness.Package, pkg.Errors[0])
+	}
+	obj := pkg.Types.Scope().Lookup(harness.Function)
+	if obj == nil {
+		return xerrors.Errorf("function %q not found in %q", harness.Function, harness.Package)
+	}
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return xerrors.Errorf("%q in %q is not a function", harness.Function, harness.Package)
+	}
+	if sig := fn.Type().(*types.Signature); !isFuzzFunc(sig) {
+		return xerrors.Errorf("%q has signature %s, expected func([]byte) int",
+			harness.Function, sig)
+	}
+	return nil
+}
+
+// isFuzzFunc checks whether the signature is func([]byte) int.
+func isFuzzFunc(sig *types.Signature) bool {
+	if sig.Recv() != nil || sig.Variadic() {
+		return false
+	}
+	if sig.Params().Len() != 1 || sig.Results().Len() != 1 {
+		return false
+	}
+	return types.Identical(sig.Params().At(0).Type(), types.NewSlice(types.Typ[types.Byte])) &&
+		types.Identical(sig.Results().At(0).Type(), types.Typ[types.Int])
+}
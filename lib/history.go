@@ -0,0 +1,181 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/xerrors"
+)
+
+// StatusHistoryPath returns the path of the go-fuzz status history in the
+// workdir. It contains one JSON encoded status per line.
+func StatusHistoryPath(workdir string) string {
+	return filepath.Join(workdir, "status.jsonl")
+}
+
+// maxHistorySize bounds the size of the status history. When it is exceeded,
+// the history is downsampled to half its entries.
+const maxHistorySize = 4 << 20
+
+// AppendStatus appends the status to the status history of the workdir.
+func AppendStatus(workdir string, status Status) error {
+	raw, err := json.Marshal(status)
+	if err != nil {
+		return xerrors.Errorf("unable to encode status: %w", err)
+	}
+	f, err := os.OpenFile(StatusHistoryPath(workdir), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return xerrors.Errorf("unable to open status history: %w", err)
+	}
+	if _, err := f.Write(append(raw, '\n')); err != nil {
+		f.Close()
+		return xerrors.Errorf("unable to write status history: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return xerrors.Errorf("unable to stat status history: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return xerrors.Errorf("unable to write status history: %w", err)
+	}
+	if info.Size() > maxHistorySize {
+		return compactStatusHistory(workdir)
+	}
+	return nil
+}
+
+// compactStatusHistory drops every second status from the history, keeping
+// the first and last one. The history is replaced atomically, such that
+// concurrent readers never see a partial file.
+func compactStatusHistory(workdir string) error {
+	history, err := ReadStatusHistory(workdir, 0)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for i, status := range history {
+		if i%2 != 0 && i != len(history)-1 {
+			continue
+		}
+		raw, err := json.Marshal(status)
+		if err != nil {
+			return xerrors.Errorf("unable to encode status: %w", err)
+		}
+		buf.Write(append(raw, '\n'))
+	}
+	if err := writeFileAtomic(StatusHistoryPath(workdir), buf.Bytes()); err != nil {
+		return xerrors.Errorf("unable to compact status history: %w", err)
+	}
+	return nil
+}
+
+// ReadStatusHistory reads the status history of the workdir. If maxPoints is
+// positive, the history is downsampled while reading to at most twice that
+// many entries, always including the first and the last one. A missing history
+// is empty. A truncated last line, e.g., from a concurrent write, is ignored.
+func ReadStatusHistory(workdir string, maxPoints int) ([]Status, error) {
+	f, err := os.Open(StatusHistoryPath(workdir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("unable to open status history: %w", err)
+	}
+	defer f.Close()
+	var history []Status
+	var last Status
+	// Every stride-th status is kept. The stride doubles whenever the kept
+	// entries exceed the bound.
+	stride, n := 1, 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var status Status
+		if err := json.Unmarshal(scanner.Bytes(), &status); err != nil {
+			continue
+		}
+		if n%stride == 0 {
+			history = append(history, status)
+		}
+		n++
+		last = status
+		if maxPoints > 0 && len(history) >= 2*maxPoints {
+			halved := history[:0]
+			for i := 0; i < len(history); i += 2 {
+				halved = append(halved, history[i])
+			}
+			history = halved
+			stride *= 2
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, xerrors.Errorf("unable to read status history: %w", err)
+	}
+	if n > 0 && (n-1)%stride != 0 {
+		history = append(history, last)
+	}
+	return history, nil
+}
+
+// lastStatusWindow is the size of the tail of the status history that is
+// read to find the last status. It fits many encoded statuses.
+const lastStatusWindow = 8 << 10
+
+// LastStatus returns the last status in the status history of the workdir,
+// or nil if there is none. Only the tail of the history is read.
+func LastStatus(workdir string) (*Status, error) {
+	f, err := os.Open(StatusHistoryPath(workdir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("unable to open status history: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, xerrors.Errorf("unable to stat status history: %w", err)
+	}
+	offset := info.Size() - lastStatusWindow
+	if offset < 0 {
+		offset = 0
+	}
+	raw := make([]byte, info.Size()-offset)
+	if _, err := f.ReadAt(raw, offset); err != nil && err != io.EOF {
+		return nil, xerrors.Errorf("unable to read status history: %w", err)
+	}
+	lines := bytes.Split(raw, []byte("\n"))
+	for i := len(lines) - 1; i >= 0; i-- {
+		var status Status
+		if err := json.Unmarshal(lines[i], &status); err == nil {
+			return &status, nil
+		}
+	}
+	return nil, nil
+}
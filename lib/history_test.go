@@ -0,0 +1,105 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/oncilla/fuzzinator/lib"
+)
+
+func TestStatusHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fuzzinator-history")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	history, err := lib.ReadStatusHistory(dir, 0)
+	require.NoError(t, err)
+	assert.Empty(t, history)
+
+	require.NoError(t, lib.AppendStatus(dir, lib.Status{Cover: 1}))
+	require.NoError(t, lib.AppendStatus(dir, lib.Status{Cover: 2}))
+	// Simulate a partially written status.
+	f, err := os.OpenFile(lib.StatusHistoryPath(dir), os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"cover": 3`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	history, err = lib.ReadStatusHistory(dir, 0)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, 1, history[0].Cover)
+	assert.Equal(t, 2, history[1].Cover)
+
+	last, err := lib.LastStatus(dir)
+	require.NoError(t, err)
+	require.NotNil(t, last)
+	assert.Equal(t, 2, last.Cover)
+}
+
+func TestStatusHistoryBounded(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fuzzinator-history")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	last, err := lib.LastStatus(dir)
+	require.NoError(t, err)
+	assert.Nil(t, last)
+
+	// Fill the history beyond its size bound in one go.
+	var buf bytes.Buffer
+	var n int
+	for buf.Len() <= 4<<20 {
+		raw, err := json.Marshal(lib.Status{Cover: n})
+		require.NoError(t, err)
+		buf.Write(append(raw, '\n'))
+		n++
+	}
+	require.NoError(t, ioutil.WriteFile(lib.StatusHistoryPath(dir), buf.Bytes(), 0644))
+
+	history, err := lib.ReadStatusHistory(dir, 100)
+	require.NoError(t, err)
+	assert.True(t, len(history) <= 201, len(history))
+	assert.Equal(t, 0, history[0].Cover)
+	assert.Equal(t, n-1, history[len(history)-1].Cover)
+
+	require.NoError(t, lib.AppendStatus(dir, lib.Status{Cover: n}))
+	info, err := os.Stat(lib.StatusHistoryPath(dir))
+	require.NoError(t, err)
+	assert.True(t, info.Size() < 3<<20, info.Size())
+	history, err = lib.ReadStatusHistory(dir, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 0, history[0].Cover)
+	assert.Equal(t, n, history[len(history)-1].Cover)
+	last, err = lib.LastStatus(dir)
+	require.NoError(t, err)
+	assert.Equal(t, n, last.Cover)
+}
@@ -0,0 +1,114 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/oncilla/fuzzinator/conf"
+)
+
+// Hook events.
+const (
+	EventCrash   = "crash"
+	EventFinish  = "finish"
+	EventPlateau = "plateau"
+)
+
+// hookTimeout bounds the execution time of a single hook.
+const hookTimeout = 30 * time.Second
+
+// HookEvent is the JSON payload passed to hooks.
+type HookEvent struct {
+	Event   string    `json:"event"`
+	Target  string    `json:"target"`
+	Commit  string    `json:"commit"`
+	Workdir string    `json:"workdir"`
+	Time    time.Time `json:"time"`
+	// Crasher is set for crash events.
+	Crasher *Crasher `json:"crasher,omitempty"`
+	// CrasherPath is the location of the exported crashing input, see
+	// StorageLocation.
+	CrasherPath string `json:"crasher_path,omitempty"`
+	// Status is the last go-fuzz status, if any.
+	Status *Status `json:"status,omitempty"`
+}
+
+// RunHook runs the hook for the event.
+func RunHook(hook conf.Hook, event HookEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return xerrors.Errorf("unable to encode hook payload: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+	if hook.Command != "" {
+		return runCommandHook(ctx, hook.Command, event, payload)
+	}
+	return runHTTPHook(ctx, hook.URL, payload)
+}
+
+func runCommandHook(ctx context.Context, command string, event HookEvent,
+	payload []byte) error {
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"FUZZINATOR_EVENT="+event.Event,
+		"FUZZINATOR_TARGET="+event.Target,
+		"FUZZINATOR_COMMIT="+event.Commit,
+		"FUZZINATOR_WORKDIR="+event.Workdir,
+		"FUZZINATOR_CRASHER="+event.CrasherPath,
+	)
+	if err := cmd.Run(); err != nil {
+		return xerrors.Errorf("hook command %q failed: %w", command, err)
+	}
+	return nil
+}
+
+func runHTTPHook(ctx context.Context, url string, payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return xerrors.Errorf("unable to create hook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return xerrors.Errorf("hook request to %s failed: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return xerrors.Errorf("hook request to %s failed: %s", url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/oncilla/fuzzinator/conf"
+	"github.com/oncilla/fuzzinator/lib"
+)
+
+func TestRunHook(t *testing.T) {
+	event := lib.HookEvent{
+		Event:       lib.EventCrash,
+		Target:      "fuzz",
+		Commit:      "08d71df58cfedc3e3fabb7b84008b1a36bf5dd03",
+		Crasher:     &lib.Crasher{Name: "a", Signature: "0123456789ab", Title: "panic: boom"},
+		CrasherPath: "crashers/a",
+	}
+
+	t.Run("command", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "fuzzinator-hooks")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		out := filepath.Join(dir, "out")
+		hook := conf.Hook{
+			Command: `cat > ` + out + ` && echo "$FUZZINATOR_EVENT $FUZZINATOR_TARGET" >> ` + out,
+		}
+		require.NoError(t, lib.RunHook(hook, event))
+		raw, err := ioutil.ReadFile(out)
+		require.NoError(t, err)
+		var decoded lib.HookEvent
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		require.NoError(t, dec.Decode(&decoded))
+		assert.Equal(t, event.Crasher, decoded.Crasher)
+		assert.Contains(t, string(raw), "crash fuzz\n")
+
+		assert.Error(t, lib.RunHook(conf.Hook{Command: "exit 1"}, event))
+	})
+	t.Run("http", func(t *testing.T) {
+		var received lib.HookEvent
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		}))
+		defer srv.Close()
+		require.NoError(t, lib.RunHook(conf.Hook{URL: srv.URL}, event))
+		assert.Equal(t, event.CrasherPath, received.CrasherPath)
+		assert.Equal(t, event.Crasher, received.Crasher)
+
+		fail := func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		failing := httptest.NewServer(http.HandlerFunc(fail))
+		defer failing.Close()
+		assert.Error(t, lib.RunHook(conf.Hook{URL: failing.URL}, event))
+	})
+}
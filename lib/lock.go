@@ -0,0 +1,169 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// ErrWorkdirLocked indicates that the workdir is in use by another process.
+var ErrWorkdirLocked = xerrors.New("workdir is in use by another process")
+
+// LockOwner describes the process that holds a workdir lock.
+type LockOwner struct {
+	PID   int       `json:"pid"`
+	Since time.Time `json:"since"`
+}
+
+// WorkdirLockedError indicates that the workdir is locked by the owner. It
+// matches ErrWorkdirLocked with xerrors.Is.
+type WorkdirLockedError struct {
+	Workdir string
+	// Owner is nil if the lock owner is unknown.
+	Owner *LockOwner
+}
+
+func (e *WorkdirLockedError) Error() string {
+	if e.Owner == nil {
+		return fmt.Sprintf("workdir %s is in use by another process", e.Workdir)
+	}
+	return fmt.Sprintf("workdir %s is in use by PID %d since %s", e.Workdir,
+		e.Owner.PID, e.Owner.Since.Format(time.RFC3339))
+}
+
+// Is reports whether the target is ErrWorkdirLocked.
+func (e *WorkdirLockedError) Is(target error) bool {
+	return target == ErrWorkdirLocked
+}
+
+// WorkdirLock is an advisory lock on a workdir. It is released when the
+// process exits.
+type WorkdirLock struct {
+	file *os.File
+}
+
+// LockPath returns the path of the lock file of the workdir. It is located
+// next to the workdir, such that the workdir can be removed while locked.
+func LockPath(workdir string) string {
+	return workdir + ".lock"
+}
+
+// LockWorkdir acquires the lock on the workdir. If the workdir is locked by
+// another process, a WorkdirLockedError is returned.
+func LockWorkdir(workdir string) (*WorkdirLock, error) {
+	if err := os.MkdirAll(WorkdirRoot(), 0755); err != nil {
+		return nil, xerrors.Errorf("unable to create workdir root: %w", err)
+	}
+	file, err := os.OpenFile(LockPath(workdir), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, xerrors.Errorf("unable to open lock file: %w", err)
+	}
+	if err := tryLock(file); err != nil {
+		file.Close()
+		if err == ErrWorkdirLocked {
+			return nil, &WorkdirLockedError{Workdir: workdir, Owner: readLockOwner(workdir)}
+		}
+		return nil, err
+	}
+	if err := writeLockOwner(file); err != nil {
+		unlock(file)
+		file.Close()
+		return nil, err
+	}
+	return &WorkdirLock{file: file}, nil
+}
+
+// WaitLockWorkdir acquires the lock on the workdir. If the workdir is locked
+// by another process, it waits until the lock is released or stop is closed.
+func WaitLockWorkdir(workdir string, stop <-chan struct{}) (*WorkdirLock, error) {
+	for {
+		lock, err := LockWorkdir(workdir)
+		if !xerrors.Is(err, ErrWorkdirLocked) {
+			return lock, err
+		}
+		select {
+		case <-stop:
+			return nil, xerrors.Errorf("stopped waiting: %w", err)
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// WorkdirInUse indicates whether the workdir is locked by another process.
+// The lock is only probed with a short-lived shared lock on an existing lock
+// file. No file is created and the lock owner is left untouched.
+func WorkdirInUse(workdir string) bool {
+	file, err := os.Open(LockPath(workdir))
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	if err := tryLockShared(file); err != nil {
+		return err == ErrWorkdirLocked
+	}
+	unlock(file)
+	return false
+}
+
+// Unlock releases the lock.
+func (l *WorkdirLock) Unlock() error {
+	if err := unlock(l.file); err != nil {
+		l.file.Close()
+		return xerrors.Errorf("unable to release workdir lock: %w", err)
+	}
+	return l.file.Close()
+}
+
+func writeLockOwner(file *os.File) error {
+	raw, err := json.Marshal(LockOwner{PID: os.Getpid(), Since: time.Now()})
+	if err != nil {
+		return xerrors.Errorf("unable to encode lock owner: %w", err)
+	}
+	if err := file.Truncate(0); err != nil {
+		return xerrors.Errorf("unable to write lock owner: %w", err)
+	}
+	if _, err := file.WriteAt(raw, 0); err != nil {
+		return xerrors.Errorf("unable to write lock owner: %w", err)
+	}
+	return nil
+}
+
+// readLockOwner returns the owner of the workdir lock, or nil if it cannot be
+// determined.
+func readLockOwner(workdir string) *LockOwner {
+	raw, err := ioutil.ReadFile(LockPath(workdir))
+	if err != nil {
+		return nil
+	}
+	var owner LockOwner
+	if err := json.Unmarshal(raw, &owner); err != nil || owner.PID == 0 {
+		return nil
+	}
+	return &owner
+}
@@ -0,0 +1,108 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/oncilla/fuzzinator/lib"
+)
+
+func TestLockWorkdir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fuzzinator-lock")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	lib.SetWorkdirRoot(dir)
+	defer lib.SetWorkdirRoot(lib.DefaultWorkdirRoot())
+
+	workdir := filepath.Join(dir, "fuzz_08d71df58cfedc3e3fabb7b84008b1a36bf5dd03")
+	lock, err := lib.LockWorkdir(workdir)
+	require.NoError(t, err)
+	_, err = lib.LockWorkdir(workdir)
+	assert.True(t, xerrors.Is(err, lib.ErrWorkdirLocked), err)
+	var locked *lib.WorkdirLockedError
+	require.True(t, xerrors.As(err, &locked), err)
+	require.NotNil(t, locked.Owner)
+	assert.Equal(t, os.Getpid(), locked.Owner.PID)
+	assert.Contains(t, err.Error(), fmt.Sprintf("in use by PID %d since", os.Getpid()))
+
+	other, err := lib.LockWorkdir(filepath.Join(dir, "other"))
+	require.NoError(t, err)
+	require.NoError(t, other.Unlock())
+
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		waited, err := lib.WaitLockWorkdir(workdir, stop)
+		if err == nil {
+			err = waited.Unlock()
+		}
+		done <- err
+	}()
+	require.NoError(t, lock.Unlock())
+	require.NoError(t, <-done)
+
+	lock, err = lib.LockWorkdir(workdir)
+	require.NoError(t, err)
+	defer lock.Unlock()
+	close(stop)
+	_, err = lib.WaitLockWorkdir(workdir, stop)
+	assert.True(t, xerrors.Is(err, lib.ErrWorkdirLocked), err)
+}
+
+func TestWorkdirInUse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fuzzinator-lock")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	lib.SetWorkdirRoot(dir)
+	defer lib.SetWorkdirRoot(lib.DefaultWorkdirRoot())
+
+	workdir := filepath.Join(dir, "fuzz_08d71df58cfedc3e3fabb7b84008b1a36bf5dd03")
+	assert.False(t, lib.WorkdirInUse(workdir))
+	_, err = os.Stat(lib.LockPath(workdir))
+	assert.True(t, os.IsNotExist(err), "probe must not create the lock file")
+
+	lock, err := lib.LockWorkdir(workdir)
+	require.NoError(t, err)
+	owner, err := ioutil.ReadFile(lib.LockPath(workdir))
+	require.NoError(t, err)
+	assert.True(t, lib.WorkdirInUse(workdir))
+	probed, err := ioutil.ReadFile(lib.LockPath(workdir))
+	require.NoError(t, err)
+	assert.Equal(t, owner, probed)
+	require.NoError(t, lock.Unlock())
+
+	assert.False(t, lib.WorkdirInUse(workdir))
+	// The probe does not hold the lock.
+	lock, err = lib.LockWorkdir(workdir)
+	require.NoError(t, err)
+	require.NoError(t, lock.Unlock())
+}
@@ -0,0 +1,134 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib
+
+import (
+	"io/ioutil"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var metricLabels = []string{"target", "commit"}
+
+var (
+	workersDesc = prometheus.NewDesc("fuzzinator_workers",
+		"Number of go-fuzz workers.", metricLabels, nil)
+	execsDesc = prometheus.NewDesc("fuzzinator_execs_total",
+		"Total number of executions of the fuzzing function.", metricLabels, nil)
+	execsPerSecDesc = prometheus.NewDesc("fuzzinator_execs_per_second",
+		"Executions per second as reported by go-fuzz.", metricLabels, nil)
+	coverDesc = prometheus.NewDesc("fuzzinator_cover",
+		"Coverage as reported by go-fuzz.", metricLabels, nil)
+	corpusDesc = prometheus.NewDesc("fuzzinator_corpus_size",
+		"Number of corpus inputs as reported by go-fuzz.", metricLabels, nil)
+	lastInputDesc = prometheus.NewDesc("fuzzinator_last_input_seconds",
+		"Time since the last new corpus input.", metricLabels, nil)
+	crashersDesc = prometheus.NewDesc("fuzzinator_crashers",
+		"Number of crashers as reported by go-fuzz.", metricLabels, nil)
+	restartsDesc = prometheus.NewDesc("fuzzinator_restarts_total",
+		"Number of restarts of go-fuzz by fuzzinator.", metricLabels, nil)
+	uptimeDesc = prometheus.NewDesc("fuzzinator_uptime_seconds",
+		"Uptime of go-fuzz.", metricLabels, nil)
+	workdirCorpusDesc = prometheus.NewDesc("fuzzinator_workdir_corpus_entries",
+		"Number of files in the corpus directory of the workdir.", metricLabels, nil)
+	workdirCrashersDesc = prometheus.NewDesc("fuzzinator_workdir_crashers",
+		"Number of crashers in the crashers directory of the workdir.", metricLabels, nil)
+)
+
+// RunCounters are the counters of a fuzzing run. They are cumulative over the
+// restarts of go-fuzz, which resets its own counters when it is restarted.
+type RunCounters struct {
+	// Execs is the number of executions of all go-fuzz processes.
+	Execs uint64
+	// Restarts is the number of restarts of go-fuzz, e.g., by the watchdog or
+	// to load the corpus.
+	Restarts uint64
+}
+
+// MetricsCollector is a prometheus collector that exposes the last go-fuzz
+// status, the run counters and the workdir contents of a fuzzing target.
+type MetricsCollector struct {
+	target  string
+	commit  string
+	workdir string
+
+	mtx      sync.Mutex
+	status   *Status
+	counters RunCounters
+}
+
+// NewMetricsCollector creates a metrics collector for the target.
+func NewMetricsCollector(target, commit, workdir string) *MetricsCollector {
+	return &MetricsCollector{target: target, commit: commit, workdir: workdir}
+}
+
+// Update sets the last go-fuzz status and the run counters.
+func (c *MetricsCollector) Update(status Status, counters RunCounters) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.status = &status
+	c.counters = counters
+}
+
+// Describe implements prometheus.Collector.
+func (c *MetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{workersDesc, execsDesc, execsPerSecDesc,
+		coverDesc, corpusDesc, lastInputDesc, crashersDesc, restartsDesc, uptimeDesc,
+		workdirCorpusDesc, workdirCrashersDesc} {
+
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *MetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	gauge := func(desc *prometheus.Desc, value float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value,
+			c.target, c.commit)
+	}
+	counter := func(desc *prometheus.Desc, value float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, value,
+			c.target, c.commit)
+	}
+	c.mtx.Lock()
+	status, counters := c.status, c.counters
+	c.mtx.Unlock()
+	if status != nil {
+		gauge(workersDesc, float64(status.Workers))
+		counter(execsDesc, float64(counters.Execs))
+		gauge(execsPerSecDesc, status.ExecsPerSec)
+		gauge(coverDesc, float64(status.Cover))
+		gauge(corpusDesc, float64(status.Corpus))
+		gauge(lastInputDesc, status.LastInput.Seconds())
+		gauge(crashersDesc, float64(status.Crashers))
+		counter(restartsDesc, float64(counters.Restarts))
+		gauge(uptimeDesc, status.Uptime.Seconds())
+	}
+	if files, err := ioutil.ReadDir(CorpusDir(c.workdir)); err == nil {
+		gauge(workdirCorpusDesc, float64(len(files)))
+	}
+	if crashers, err := ListCrashers(c.workdir); err == nil {
+		gauge(workdirCrashersDesc, float64(len(crashers)))
+	}
+}
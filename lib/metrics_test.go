@@ -0,0 +1,80 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/oncilla/fuzzinator/lib"
+)
+
+func TestMetricsCollector(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fuzzinator-metrics")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, os.MkdirAll(lib.CorpusDir(dir), 0755))
+	for _, name := range []string{"a", "b", "c"} {
+		file := filepath.Join(lib.CorpusDir(dir), name)
+		require.NoError(t, ioutil.WriteFile(file, []byte(name), 0644))
+	}
+
+	c := lib.NewMetricsCollector("fuzz", "08d71df5", dir)
+	// Without a status, only the workdir metrics are exposed.
+	assert.Equal(t, 2, testutil.CollectAndCount(c))
+
+	c.Update(lib.Status{
+		Workers:         2,
+		Corpus:          5,
+		Execs:           1000,
+		ExecsPerSec:     500,
+		ExecsPerRestart: 100,
+		Cover:           42,
+		Uptime:          time.Minute,
+	}, lib.RunCounters{Execs: 3000, Restarts: 2})
+	expected := `
+# HELP fuzzinator_cover Coverage as reported by go-fuzz.
+# TYPE fuzzinator_cover gauge
+fuzzinator_cover{commit="08d71df5",target="fuzz"} 42
+# HELP fuzzinator_execs_total Total number of executions of the fuzzing function.
+# TYPE fuzzinator_execs_total counter
+fuzzinator_execs_total{commit="08d71df5",target="fuzz"} 3000
+# HELP fuzzinator_restarts_total Number of restarts of go-fuzz by fuzzinator.
+# TYPE fuzzinator_restarts_total counter
+fuzzinator_restarts_total{commit="08d71df5",target="fuzz"} 2
+# HELP fuzzinator_workdir_corpus_entries Number of files in the corpus directory of the workdir.
+# TYPE fuzzinator_workdir_corpus_entries gauge
+fuzzinator_workdir_corpus_entries{commit="08d71df5",target="fuzz"} 3
+`
+	assert.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"fuzzinator_cover", "fuzzinator_execs_total", "fuzzinator_restarts_total",
+		"fuzzinator_workdir_corpus_entries"))
+}
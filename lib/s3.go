@@ -0,0 +1,329 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// S3Storage stores files as objects in an S3-compatible object store, e.g.,
+// AWS S3 or MinIO. Requests use path-style addressing and are signed with AWS
+// Signature Version 4. Requests are not signed if no access key is set.
+type S3Storage struct {
+	// Endpoint is the base URL of the object store, e.g.,
+	// "http://localhost:9000".
+	Endpoint string
+	Region   string
+	Bucket   string
+	// Prefix is the key prefix of all files, without trailing slash.
+	Prefix       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	// Client is the HTTP client. If it is not set, a client with a timeout is
+	// used.
+	Client *http.Client
+}
+
+// OpenS3Storage opens the storage at a location of the form
+// s3://bucket/prefix. The endpoint and region are taken from the "endpoint"
+// and "region" query parameters, or from the AWS_ENDPOINT_URL_S3,
+// AWS_ENDPOINT_URL, and AWS_REGION environment variables. The credentials are
+// taken from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+func OpenS3Storage(location string) (*S3Storage, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, xerrors.Errorf("invalid s3 location %q: %w", location, err)
+	}
+	if u.Scheme != SchemeS3 || u.Host == "" {
+		return nil, xerrors.Errorf("invalid s3 location %q, must be s3://bucket/prefix", location)
+	}
+	q := u.Query()
+	endpoint := firstNonEmpty(q.Get("endpoint"),
+		os.Getenv("AWS_ENDPOINT_URL_S3"), os.Getenv("AWS_ENDPOINT_URL"))
+	region := firstNonEmpty(q.Get("region"),
+		os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), "us-east-1")
+	s := &S3Storage{
+		Endpoint:     endpoint,
+		Region:       region,
+		Bucket:       u.Host,
+		Prefix:       strings.Trim(u.Path, "/"),
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if s.Endpoint == "" {
+		s.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", s.Region)
+	}
+	return s, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// List returns the names of all objects below the prefix.
+func (s *S3Storage) List() ([]string, error) {
+	prefix := ""
+	if s.Prefix != "" {
+		prefix = s.Prefix + "/"
+	}
+	var names []string
+	var token string
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		raw, err := s.do(http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key string
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		if err := xml.Unmarshal(raw, &result); err != nil {
+			return nil, xerrors.Errorf("unable to decode object listing: %w", err)
+		}
+		for _, obj := range result.Contents {
+			name := strings.TrimPrefix(obj.Key, prefix)
+			// Keys ending in a slash are directory markers.
+			if name == "" || strings.HasSuffix(name, "/") {
+				continue
+			}
+			names = append(names, name)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *S3Storage) Read(name string) ([]byte, error) {
+	key, err := s.key(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.do(http.MethodGet, key, nil, nil)
+}
+
+func (s *S3Storage) Write(name string, raw []byte) error {
+	key, err := s.key(name)
+	if err != nil {
+		return err
+	}
+	_, err = s.do(http.MethodPut, key, nil, raw)
+	return err
+}
+
+func (s *S3Storage) Close() error {
+	return nil
+}
+
+func (s *S3Storage) key(name string) (string, error) {
+	clean, err := cleanName(name)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(s.Prefix, clean), nil
+}
+
+// do sends the request for the object key, or the bucket if the key is
+// empty, and returns the response body. Missing objects and buckets result in
+// an error that wraps os.ErrNotExist.
+func (s *S3Storage) do(method, key string, query url.Values, body []byte) ([]byte, error) {
+	u, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, xerrors.Errorf("invalid s3 endpoint %q: %w", s.Endpoint, err)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.Bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	// The path and query are sent in their canonical form, such that the
+	// signature matches the request the server receives.
+	u.RawPath = s3Escape(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, xerrors.Errorf("invalid s3 request: %w", err)
+	}
+	sum := sha256.Sum256(body)
+	if s.AccessKey != "" {
+		s.Sign(req, hex.EncodeToString(sum[:]), time.Now())
+	}
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: time.Minute}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, xerrors.Errorf("unable to reach s3 endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, xerrors.Errorf("unable to read s3 response: %w", err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return raw, nil
+	}
+	var s3Err struct {
+		Code    string
+		Message string
+	}
+	if xml.Unmarshal(raw, &s3Err) != nil || s3Err.Code == "" {
+		s3Err.Code, s3Err.Message = resp.Status, strings.TrimSpace(string(raw))
+	}
+	target := fmt.Sprintf("s3://%s/%s", s.Bucket, key)
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, xerrors.Errorf("%s %s: %s: %w", method, target, s3Err.Code, os.ErrNotExist)
+	}
+	return nil, xerrors.Errorf("%s %s failed: %s: %s", method, target, s3Err.Code, s3Err.Message)
+}
+
+// Sign signs the request with AWS Signature Version 4 for the S3 service. The
+// host and all headers of the request are signed. The payload hash is the hex
+// encoded SHA256 hash of the request body.
+func (s *S3Storage) Sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		value := strings.Join(values, ",")
+		headers[strings.ToLower(name)] = strings.Join(strings.Fields(value), " ")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3Escape(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, s.Region)
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:]),
+	}, "\n")
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	for _, part := range []string{s.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery returns the query sorted by key with strictly escaped keys
+// and values.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return s3Escape(keys[i], true) < s3Escape(keys[j], true)
+	})
+	var params []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			params = append(params, s3Escape(key, true)+"="+s3Escape(value, true))
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// s3Escape percent-encodes all bytes except the unreserved characters. Slashes
+// are only encoded if encodeSlash is set.
+func s3Escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
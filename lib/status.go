@@ -0,0 +1,180 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Status is a status line reported by go-fuzz.
+type Status struct {
+	// Time is the time the status was observed.
+	Time    time.Time `json:"time"`
+	Workers int       `json:"workers"`
+	Corpus  int       `json:"corpus"`
+	// LastInput is the time since the last new corpus input.
+	LastInput time.Duration `json:"last_input"`
+	Crashers  int           `json:"crashers"`
+	// ExecsPerRestart is the number of executions per restart of the
+	// fuzzing binary. Zero indicates no restarts.
+	ExecsPerRestart uint64        `json:"execs_per_restart"`
+	Execs           uint64        `json:"execs"`
+	ExecsPerSec     float64       `json:"execs_per_sec"`
+	Cover           int           `json:"cover"`
+	Uptime          time.Duration `json:"uptime"`
+}
+
+var statusPattern = regexp.MustCompile(`workers: (\d+), corpus: (\d+) \((\S+) ago\), ` +
+	`crashers: (\d+), restarts: 1/(\d+), execs: (\d+) \((\d+)/sec\), cover: (\d+), ` +
+	`uptime: (\S+)`)
+
+// ParseStatus parses a go-fuzz status line, e.g.,
+//
+//	workers: 8, corpus: 124 (3s ago), crashers: 1, restarts: 1/9851,
+//	execs: 1970369 (21879/sec), cover: 1057, uptime: 1m30s
+//
+// The boolean indicates whether the line is a status line.
+func ParseStatus(line string) (Status, bool) {
+	m := statusPattern.FindStringSubmatch(line)
+	if m == nil {
+		return Status{}, false
+	}
+	atoi := func(s string) int {
+		i, _ := strconv.Atoi(s)
+		return i
+	}
+	atou := func(s string) uint64 {
+		u, _ := strconv.ParseUint(s, 10, 64)
+		return u
+	}
+	// go-fuzz prints durations that time.ParseDuration understands, except
+	// for inputs that were never found.
+	duration := func(s string) time.Duration {
+		d, _ := time.ParseDuration(s)
+		return d
+	}
+	return Status{
+		Time:            time.Now(),
+		Workers:         atoi(m[1]),
+		Corpus:          atoi(m[2]),
+		LastInput:       duration(m[3]),
+		Crashers:        atoi(m[4]),
+		ExecsPerRestart: atou(m[5]),
+		Execs:           atou(m[6]),
+		ExecsPerSec:     float64(atou(m[7])),
+		Cover:           atoi(m[8]),
+		Uptime:          duration(m[9]),
+	}, true
+}
+
+// StatusWriter is an io.Writer that parses the go-fuzz output line by line
+// and calls the callback for every status line.
+type StatusWriter struct {
+	mtx      sync.Mutex
+	buf      []byte
+	onStatus func(Status)
+}
+
+// NewStatusWriter returns a status writer that calls onStatus for every
+// status line.
+func NewStatusWriter(onStatus func(Status)) *StatusWriter {
+	return &StatusWriter{onStatus: onStatus}
+}
+
+func (w *StatusWriter) Write(p []byte) (int, error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if status, ok := ParseStatus(string(w.buf[:i])); ok {
+			w.onStatus(status)
+		}
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// PlateauDetector detects when coverage stops growing.
+type PlateauDetector struct {
+	// Window is the duration without coverage growth after which fuzzing is
+	// considered to have plateaued.
+	Window time.Duration
+	// CorpusWindow is the duration without corpus growth. If it is set,
+	// fuzzing is only considered to have plateaued if the corpus did not grow
+	// within this window either.
+	CorpusWindow time.Duration
+
+	cover       int
+	corpus      int
+	grown       time.Time
+	corpusGrown time.Time
+	plateaued   bool
+}
+
+// Observe records the status. It returns true if the status starts a
+// plateau, i.e., the coverage has not grown for the window. A plateau is only
+// reported once, until coverage grows again.
+func (d *PlateauDetector) Observe(s Status) bool {
+	if d.grown.IsZero() || s.Cover > d.cover {
+		d.cover, d.grown, d.plateaued = s.Cover, s.Time, false
+	}
+	if d.corpusGrown.IsZero() || s.Corpus > d.corpus {
+		d.corpus, d.corpusGrown = s.Corpus, s.Time
+		if d.CorpusWindow > 0 {
+			d.plateaued = false
+		}
+	}
+	if d.plateaued || s.Time.Sub(d.grown) < d.Window {
+		return false
+	}
+	if d.CorpusWindow > 0 && s.Time.Sub(d.corpusGrown) < d.CorpusWindow {
+		return false
+	}
+	d.plateaued = true
+	return true
+}
+
+// Since returns the time since the coverage last grew.
+func (d *PlateauDetector) Since(now time.Time) time.Duration {
+	return now.Sub(d.grown)
+}
+
+// Reason describes why fuzzing is considered to have plateaued.
+func (d *PlateauDetector) Reason(now time.Time) string {
+	reason := fmt.Sprintf("cover %d has not grown for %s (window %s)", d.cover,
+		d.Since(now).Round(time.Second), d.Window)
+	if d.CorpusWindow > 0 {
+		reason += fmt.Sprintf(", corpus %d has not grown for %s (window %s)", d.corpus,
+			now.Sub(d.corpusGrown).Round(time.Second), d.CorpusWindow)
+	}
+	return reason
+}
@@ -0,0 +1,140 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/oncilla/fuzzinator/lib"
+)
+
+func TestParseStatus(t *testing.T) {
+	line := "2019/11/03 12:00:00 workers: 8, corpus: 124 (3s ago), crashers: 1, " +
+		"restarts: 1/9851, execs: 1970369 (21879/sec), cover: 1057, uptime: 1m30s"
+	status, ok := lib.ParseStatus(line)
+	require.True(t, ok)
+	assert.Equal(t, 8, status.Workers)
+	assert.Equal(t, 124, status.Corpus)
+	assert.Equal(t, 3*time.Second, status.LastInput)
+	assert.Equal(t, 1, status.Crashers)
+	assert.Equal(t, uint64(9851), status.ExecsPerRestart)
+	assert.Equal(t, uint64(1970369), status.Execs)
+	assert.Equal(t, float64(21879), status.ExecsPerSec)
+	assert.Equal(t, 1057, status.Cover)
+	assert.Equal(t, 90*time.Second, status.Uptime)
+
+	_, ok = lib.ParseStatus("2019/11/03 12:00:00 slowest input: 2s")
+	assert.False(t, ok)
+}
+
+func TestStatusWriter(t *testing.T) {
+	var covers []int
+	w := lib.NewStatusWriter(func(s lib.Status) {
+		covers = append(covers, s.Cover)
+	})
+	line := func(cover int) string {
+		return fmt.Sprintf("workers: 1, corpus: 1 (1s ago), crashers: 0, restarts: 1/0, "+
+			"execs: 1 (1/sec), cover: %d, uptime: 1s\n", cover)
+	}
+	first, second := line(1), line(2)
+	_, err := w.Write([]byte("other output\n" + first[:10]))
+	require.NoError(t, err)
+	assert.Empty(t, covers)
+	_, err = w.Write([]byte(first[10:] + second))
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, covers)
+}
+
+func TestPlateauDetector(t *testing.T) {
+	start := time.Now()
+	status := func(offset time.Duration, cover int) lib.Status {
+		return lib.Status{Time: start.Add(offset), Cover: cover}
+	}
+	d := lib.PlateauDetector{Window: 10 * time.Minute}
+	assert.False(t, d.Observe(status(0, 10)))
+	assert.False(t, d.Observe(status(5*time.Minute, 10)))
+	assert.False(t, d.Observe(status(6*time.Minute, 11)))
+	assert.False(t, d.Observe(status(15*time.Minute, 11)))
+	assert.True(t, d.Observe(status(16*time.Minute, 11)))
+	assert.Equal(t, 10*time.Minute, d.Since(start.Add(16*time.Minute)))
+	// A plateau is only reported once.
+	assert.False(t, d.Observe(status(20*time.Minute, 11)))
+	assert.False(t, d.Observe(status(21*time.Minute, 12)))
+	assert.True(t, d.Observe(status(31*time.Minute, 12)))
+}
+
+func TestPlateauDetectorCorpus(t *testing.T) {
+	start := time.Now()
+	status := func(offset time.Duration, cover, corpus int) lib.Status {
+		return lib.Status{Time: start.Add(offset), Cover: cover, Corpus: corpus}
+	}
+	d := lib.PlateauDetector{Window: 10 * time.Minute, CorpusWindow: 20 * time.Minute}
+	assert.False(t, d.Observe(status(0, 10, 5)))
+	assert.False(t, d.Observe(status(5*time.Minute, 10, 6)))
+	// Coverage plateaued, but the corpus still grows.
+	assert.False(t, d.Observe(status(15*time.Minute, 10, 6)))
+	assert.False(t, d.Observe(status(24*time.Minute, 10, 6)))
+	assert.True(t, d.Observe(status(25*time.Minute, 10, 6)))
+	assert.Equal(t, "cover 10 has not grown for 25m0s (window 10m0s), "+
+		"corpus 6 has not grown for 20m0s (window 20m0s)", d.Reason(start.Add(25*time.Minute)))
+	assert.False(t, d.Observe(status(26*time.Minute, 10, 6)))
+	// Corpus growth ends the plateau.
+	assert.False(t, d.Observe(status(27*time.Minute, 10, 7)))
+	assert.True(t, d.Observe(status(47*time.Minute, 10, 7)))
+}
+
+func TestWatchdog(t *testing.T) {
+	start := time.Now()
+	status := func(offset time.Duration, execsPerSec float64) lib.Status {
+		return lib.Status{Time: start.Add(offset), ExecsPerSec: execsPerSec}
+	}
+	w := lib.Watchdog{Stall: time.Minute}
+	w.Reset(start)
+	_, stalled := w.Stalled(start.Add(30 * time.Second))
+	assert.False(t, stalled)
+	reason, stalled := w.Stalled(start.Add(time.Minute))
+	assert.True(t, stalled)
+	assert.Equal(t, "no status reported for 1m0s (stall 1m0s)", reason)
+
+	w.Observe(status(time.Minute, 100))
+	w.Observe(status(90*time.Second, 0))
+	_, stalled = w.Stalled(start.Add(100 * time.Second))
+	assert.False(t, stalled)
+	w.Observe(status(2*time.Minute, 0))
+	reason, stalled = w.Stalled(start.Add(2 * time.Minute))
+	assert.True(t, stalled)
+	assert.Equal(t, "execs/sec has been 0 for 1m0s (stall 1m0s)", reason)
+
+	w.Reset(start.Add(3 * time.Minute))
+	_, stalled = w.Stalled(start.Add(3 * time.Minute))
+	assert.False(t, stalled)
+	disabled := lib.Watchdog{}
+	disabled.Reset(start)
+	_, stalled = disabled.Stalled(start.Add(time.Hour))
+	assert.False(t, stalled)
+}
@@ -0,0 +1,505 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// Storage schemes of corpus and crashers locations. Locations without a
+// scheme are local directories.
+const (
+	SchemeFile = "file"
+	SchemeTar  = "tar"
+	SchemeZip  = "zip"
+	SchemeS3   = "s3"
+)
+
+// Storage stores files by name. Names are slash separated paths relative to
+// the storage root. Reading a missing file returns an error that wraps
+// os.ErrNotExist.
+type Storage interface {
+	// List returns the names of all files, sorted.
+	List() ([]string, error)
+	Read(name string) ([]byte, error)
+	Write(name string, raw []byte) error
+	// Close flushes pending writes and releases the storage.
+	Close() error
+}
+
+// OpenStorage opens the storage at the location, which is a local directory,
+// or a URL of the form:
+//
+//	file:///path/to/dir
+//	tar://path/to/archive.tar[.gz|.tgz]
+//	zip://path/to/archive.zip
+//	s3://bucket/prefix[?endpoint=http://localhost:9000&region=us-east-1]
+//
+// Archive paths are relative to the working directory, unless they start with
+// a slash. See S3Storage for the configuration of S3-compatible storage.
+func OpenStorage(location string) (Storage, error) {
+	if dir, ok := LocalStoragePath(location); ok {
+		return LocalStorage{Dir: dir}, nil
+	}
+	scheme, rest := splitScheme(location)
+	switch scheme {
+	case SchemeTar, SchemeZip:
+		return openArchive(scheme, rest)
+	case SchemeS3:
+		return OpenS3Storage(location)
+	default:
+		return nil, xerrors.Errorf("unsupported storage scheme %q in %q", scheme, location)
+	}
+}
+
+// LocalStoragePath returns the local directory of the location, if the
+// location is a local directory.
+func LocalStoragePath(location string) (string, bool) {
+	scheme, rest := splitScheme(location)
+	switch scheme {
+	case "":
+		return location, true
+	case SchemeFile:
+		return filepath.FromSlash(rest), true
+	default:
+		return "", false
+	}
+}
+
+// StorageLocation returns the location of the named file in the storage at
+// location. For local directories, it is the file path.
+func StorageLocation(location, name string) string {
+	if dir, ok := LocalStoragePath(location); ok {
+		return filepath.Join(dir, filepath.FromSlash(name))
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return strings.TrimSuffix(location, "/") + "/" + name
+	}
+	u.Path = path.Join(u.Path, name)
+	return u.String()
+}
+
+// DefaultCrashersLocation returns the location crashers are exported to if
+// none is configured. It is the "crashers" directory next to the corpus. For
+// archives, it is the local directory next to the archive.
+func DefaultCrashersLocation(corpus string) string {
+	if dir, ok := LocalStoragePath(corpus); ok {
+		return filepath.Join(filepath.Dir(dir), "crashers")
+	}
+	scheme, rest := splitScheme(corpus)
+	if scheme == SchemeTar || scheme == SchemeZip {
+		return filepath.Join(filepath.Dir(filepath.FromSlash(rest)), "crashers")
+	}
+	u, err := url.Parse(corpus)
+	if err != nil {
+		return corpus + "/crashers"
+	}
+	u.Path = path.Join(path.Dir(strings.TrimSuffix(u.Path, "/")), "crashers")
+	return u.String()
+}
+
+// splitScheme splits the location into the scheme and the remainder. The
+// scheme is empty for plain paths. Single letter schemes are windows drive
+// letters.
+func splitScheme(location string) (string, string) {
+	i := strings.Index(location, "://")
+	if i < 2 || strings.ContainsAny(location[:i], `/\`) {
+		return "", location
+	}
+	return strings.ToLower(location[:i]), location[i+len("://"):]
+}
+
+// cleanName validates the storage file name and returns its canonical form.
+func cleanName(name string) (string, error) {
+	clean := strings.TrimPrefix(path.Clean("/"+name), "/")
+	if clean == "" || clean != strings.TrimPrefix(name, "./") {
+		return "", xerrors.Errorf("invalid file name %q", name)
+	}
+	return clean, nil
+}
+
+// LocalStorage stores files in a local directory.
+type LocalStorage struct {
+	Dir string
+}
+
+// List returns the names of all regular files in the directory tree.
+func (s LocalStorage) List() ([]string, error) {
+	var names []string
+	err := filepath.Walk(s.Dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.Dir, file)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("unable to list %s: %w", s.Dir, err)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s LocalStorage) Read(name string) ([]byte, error) {
+	file, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, xerrors.Errorf("unable to read %s: %w", file, err)
+	}
+	return raw, nil
+}
+
+// Write writes the file atomically, such that concurrent readers never see a
+// partial file.
+func (s LocalStorage) Write(name string, raw []byte) error {
+	file, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return xerrors.Errorf("unable to create directory: %w", err)
+	}
+	if err := writeFileAtomic(file, raw); err != nil {
+		return xerrors.Errorf("unable to write %s: %w", file, err)
+	}
+	return nil
+}
+
+func (s LocalStorage) Close() error {
+	return nil
+}
+
+func (s LocalStorage) path(name string) (string, error) {
+	clean, err := cleanName(name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(clean)), nil
+}
+
+// writeFileAtomic writes to a temporary file next to dst and renames it.
+func writeFileAtomic(dst string, raw []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(dst), filepath.Base(dst)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// archiveStorage stores files in a tar or zip archive. The archive is read
+// into memory when it is opened, and rewritten on Close if files were
+// written. Tar archives ending in .gz or .tgz are gzip compressed.
+type archiveStorage struct {
+	format string
+	file   string
+	files  map[string][]byte
+	// missing is set if the archive did not exist when it was opened.
+	missing bool
+	dirty   bool
+}
+
+func openArchive(format, file string) (*archiveStorage, error) {
+	s := &archiveStorage{
+		format: format,
+		file:   filepath.FromSlash(file),
+		files:  make(map[string][]byte),
+	}
+	if s.file == "" {
+		return nil, xerrors.Errorf("%s storage requires an archive path", format)
+	}
+	raw, err := ioutil.ReadFile(s.file)
+	if os.IsNotExist(err) {
+		s.missing = true
+		return s, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("unable to read archive: %w", err)
+	}
+	if format == SchemeZip {
+		err = s.readZip(raw)
+	} else {
+		err = s.readTar(raw)
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("unable to read archive %s: %w", s.file, err)
+	}
+	return s, nil
+}
+
+func (s *archiveStorage) readTar(raw []byte) error {
+	var r io.Reader = bytes.NewReader(raw)
+	if s.compressed() {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !hdr.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		if err := s.add(hdr.Name, data); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *archiveStorage) readZip(raw []byte) error {
+	zr, err := zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		if err := s.add(f.Name, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *archiveStorage) add(name string, data []byte) error {
+	clean, err := cleanName(name)
+	if err != nil {
+		return err
+	}
+	s.files[clean] = data
+	return nil
+}
+
+func (s *archiveStorage) compressed() bool {
+	return strings.HasSuffix(s.file, ".gz") || strings.HasSuffix(s.file, ".tgz")
+}
+
+// List returns the names of all files in the archive. Listing an archive that
+// does not exist fails, unless files were written to it.
+func (s *archiveStorage) List() ([]string, error) {
+	if s.missing && len(s.files) == 0 {
+		return nil, xerrors.Errorf("unable to list %s: %w", s.file, os.ErrNotExist)
+	}
+	names := make([]string, 0, len(s.files))
+	for name := range s.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *archiveStorage) Read(name string) ([]byte, error) {
+	clean, err := cleanName(name)
+	if err != nil {
+		return nil, err
+	}
+	raw, ok := s.files[clean]
+	if !ok {
+		return nil, xerrors.Errorf("unable to read %s from %s: %w", name, s.file, os.ErrNotExist)
+	}
+	return raw, nil
+}
+
+func (s *archiveStorage) Write(name string, raw []byte) error {
+	clean, err := cleanName(name)
+	if err != nil {
+		return err
+	}
+	s.files[clean] = append([]byte(nil), raw...)
+	s.dirty = true
+	return nil
+}
+
+// Close rewrites the archive atomically if files were written.
+func (s *archiveStorage) Close() error {
+	if !s.dirty {
+		return nil
+	}
+	names, err := s.List()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if s.format == SchemeZip {
+		err = s.writeZip(&buf, names)
+	} else {
+		err = s.writeTar(&buf, names)
+	}
+	if err != nil {
+		return xerrors.Errorf("unable to encode archive %s: %w", s.file, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.file), 0755); err != nil {
+		return xerrors.Errorf("unable to create directory: %w", err)
+	}
+	if err := writeFileAtomic(s.file, buf.Bytes()); err != nil {
+		return xerrors.Errorf("unable to write archive %s: %w", s.file, err)
+	}
+	s.dirty, s.missing = false, false
+	return nil
+}
+
+func (s *archiveStorage) writeTar(w io.Writer, names []string) error {
+	var gz *gzip.Writer
+	if s.compressed() {
+		gz = gzip.NewWriter(w)
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	now := time.Now()
+	for _, name := range names {
+		hdr := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(s.files[name])),
+			ModTime:  now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(s.files[name]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if gz != nil {
+		return gz.Close()
+	}
+	return nil
+}
+
+func (s *archiveStorage) writeZip(w io.Writer, names []string) error {
+	zw := zip.NewWriter(w)
+	now := time.Now()
+	for _, name := range names {
+		hdr := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now}
+		hdr.SetMode(0644)
+		f, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(s.files[name]); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// SubStorage returns the storage of the files in the directory of the parent
+// storage. Closing it closes the parent.
+func SubStorage(parent Storage, dir string) Storage {
+	return subStorage{parent: parent, dir: strings.Trim(dir, "/")}
+}
+
+type subStorage struct {
+	parent Storage
+	dir    string
+}
+
+func (s subStorage) List() ([]string, error) {
+	all, err := s.parent.List()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, name := range all {
+		if strings.HasPrefix(name, s.dir+"/") {
+			names = append(names, strings.TrimPrefix(name, s.dir+"/"))
+		}
+	}
+	return names, nil
+}
+
+func (s subStorage) Read(name string) ([]byte, error) {
+	return s.parent.Read(path.Join(s.dir, name))
+}
+
+func (s subStorage) Write(name string, raw []byte) error {
+	return s.parent.Write(path.Join(s.dir, name), raw)
+}
+
+func (s subStorage) Close() error {
+	return s.parent.Close()
+}
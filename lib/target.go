@@ -24,18 +24,19 @@ package lib
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"syscall"
+	"time"
 
-	"github.com/otiai10/copy"
 	"golang.org/x/tools/go/packages"
 	"golang.org/x/xerrors"
 	"gopkg.in/src-d/go-git.v4"
-
-	"github.com/oncilla/fuzzinator/conf"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
 )
 
 // SetupTempWorkdir sets up the temporary working directory and returns the path.
@@ -47,32 +48,69 @@ func SetupTempWorkdir(targetName, commit string) (string, error) {
 	return workdir, nil
 }
 
+var workdirRoot = DefaultWorkdirRoot()
+
+// DefaultWorkdirRoot returns the default workdir root in the temp directory.
+func DefaultWorkdirRoot() string {
+	return filepath.Join(os.TempDir(), "fuzzinator")
+}
+
+// SetWorkdirRoot sets the directory that contains all workdirs.
+func SetWorkdirRoot(dir string) {
+	workdirRoot = dir
+}
+
+// WorkdirRoot returns the directory that contains all workdirs.
+func WorkdirRoot() string {
+	return workdirRoot
+}
+
 // TempWorkdir returns the temporary workdir path for a given target and commit.
 func TempWorkdir(targetName string, commit string) string {
-	return filepath.Join(os.TempDir(), "fuzzinator", fmt.Sprintf("%s_%s", targetName, commit))
+	return filepath.Join(WorkdirRoot(), fmt.Sprintf("%s_%s", targetName, commit))
 }
 
-// SetupCorpus sets up the temporary working directory with the configured corpus.
+// SetupCorpus sets up the temporary working directory with the corpus at the
+// given storage location, see OpenStorage.
 func SetupCorpus(corpus, workdir string) error {
-	if err := copy.Copy(corpus, filepath.Join(workdir, "corpus")); err != nil {
-		return xerrors.Errorf("unable to copy corpus: %w", err)
+	src, err := OpenStorage(corpus)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	names, err := src.List()
+	if err != nil {
+		return xerrors.Errorf("unable to list corpus: %w", err)
+	}
+	dst := LocalStorage{Dir: CorpusDir(workdir)}
+	if err := os.MkdirAll(dst.Dir, 0755); err != nil {
+		return xerrors.Errorf("unable to create corpus dir: %w", err)
+	}
+	for _, name := range names {
+		raw, err := src.Read(name)
+		if err != nil {
+			return xerrors.Errorf("unable to read corpus entry: %w", err)
+		}
+		if err := dst.Write(name, raw); err != nil {
+			return xerrors.Errorf("unable to copy corpus entry: %w", err)
+		}
 	}
 	return nil
 }
 
-// CopyCrashers copies the crashers from the workdir to the target directory.
-func CopyCrashers(workdir, target string) error {
-	if err := copy.Copy(filepath.Join(workdir, "crashers"), target); err != nil {
-		return xerrors.Errorf("unable to copy crashers: %w", err)
-	}
-	return nil
+// CrashersDir returns the crashers directory in the workdir.
+func CrashersDir(workdir string) string {
+	return filepath.Join(workdir, "crashers")
 }
 
-// BuildBinary builds the fuzzing binary and returns the path.
-func BuildBinary(target conf.Target, workdir string, stop <-chan struct{}) (string, error) {
+// BuildBinary builds the fuzzing binary and returns the path. The build
+// configuration is written next to the binary.
+func BuildBinary(cfg BuildConfig, workdir string, stop <-chan struct{}) (string, error) {
 	output := BinaryPath(workdir)
-	cmd := exec.Command("go-fuzz-build", "-o", output, "-tags", target.Harness.BuildTags,
-		"-func", target.Harness.Function, target.Harness.Package)
+	args := append([]string{"-o", output, "-tags", cfg.Tags, "-func", cfg.Function},
+		cfg.Args...)
+	cmd := exec.Command("go-fuzz-build", append(args, cfg.Package)...)
+	cmd.Env = buildEnv(cfg)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Start(); err != nil {
@@ -92,6 +130,9 @@ func BuildBinary(target conf.Target, workdir string, stop <-chan struct{}) (stri
 		if err != nil {
 			return "", xerrors.Errorf("error while bulding fuzzing binary: %w", err)
 		}
+		if err := WriteBuildConfig(BuildConfigPath(workdir), cfg); err != nil {
+			return "", err
+		}
 		return output, nil
 	}
 }
@@ -101,17 +142,92 @@ func BinaryPath(workdir string) string {
 	return filepath.Join(workdir, "fuzz.zip")
 }
 
-// RunBinary runs the fuzzing binary until the stop channel is closed.
-func RunBinary(fuzzBin string, workdir string, stop <-chan struct{}) error {
-	cmd := exec.Command("go-fuzz", "-bin", fuzzBin, "-workdir", workdir)
+// NicePriority is the scheduling priority go-fuzz is lowered to by Nice.
+const NicePriority = 10
+
+// stopGracePeriod is the time go-fuzz has to shut down after being asked to
+// terminate, before it is killed.
+const stopGracePeriod = 10 * time.Second
+
+// FuzzProcess is a running go-fuzz process.
+type FuzzProcess struct {
+	cmd    *exec.Cmd
+	exited chan struct{}
+	err    error
+}
+
+// StartGoFuzz starts go-fuzz with the arguments. This allows running go-fuzz
+// in coordinator or worker mode. If onStatus is set, it is called for every
+// status line go-fuzz reports.
+func StartGoFuzz(args []string, onStatus func(Status)) (*FuzzProcess, error) {
+	cmd := exec.Command("go-fuzz", args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
+	if onStatus != nil {
+		// go-fuzz logs the status lines, which might end up on either stream.
+		cmd.Stdout = io.MultiWriter(os.Stdout, NewStatusWriter(onStatus))
+		cmd.Stderr = io.MultiWriter(os.Stderr, NewStatusWriter(onStatus))
+	}
+	// go-fuzz and the processes it spawns share a process group, such that
+	// their priority can be adjusted together.
+	setProcessGroup(cmd)
 	if err := cmd.Start(); err != nil {
-		return xerrors.Errorf("unable to build fuzzing binary: %w", err)
+		return nil, xerrors.Errorf("unable to start go-fuzz: %w", err)
+	}
+	p := &FuzzProcess{cmd: cmd, exited: make(chan struct{})}
+	go func() {
+		p.err = cmd.Wait()
+		close(p.exited)
+	}()
+	return p, nil
+}
+
+// Exited is closed when go-fuzz exits.
+func (p *FuzzProcess) Exited() <-chan struct{} {
+	return p.exited
+}
+
+// Err returns the exit error of go-fuzz. It must only be called after Exited
+// is closed.
+func (p *FuzzProcess) Err() error {
+	return p.err
+}
+
+// Stop terminates go-fuzz and waits for it to exit. If it does not exit within
+// the grace period, it is killed.
+func (p *FuzzProcess) Stop() error {
+	select {
+	case <-p.exited:
+		return nil
+	default:
+	}
+	if err := p.cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		select {
+		case <-p.exited:
+			return nil
+		default:
+			return xerrors.Errorf("unable to terminate fuzzing: %w", err)
+		}
+	}
+	select {
+	case <-p.exited:
+		return nil
+	case <-time.After(stopGracePeriod):
 	}
-	<-stop
-	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
-		return xerrors.Errorf("unable to terminate fuzzing: %w", err)
+	// The processes spawned by go-fuzz might keep the output open, they are
+	// killed as well.
+	if err := killProcessGroup(p.cmd); err != nil {
+		return xerrors.Errorf("unable to kill go-fuzz: %w", err)
+	}
+	<-p.exited
+	return nil
+}
+
+// Nice lowers the scheduling priority of go-fuzz and the processes it spawned
+// to NicePriority.
+func (p *FuzzProcess) Nice() error {
+	if err := setGroupPriority(p.cmd.Process.Pid, NicePriority); err != nil {
+		return xerrors.Errorf("unable to lower priority: %w", err)
 	}
 	return nil
 }
@@ -153,6 +269,81 @@ func CommitHash(dir string) (string, error) {
 	return ref.Hash().String(), nil
 }
 
+// ReachableCommits returns all commits that are reachable from any local or
+// remote branch of the git repository at dir.
+func ReachableCommits(dir string) (map[string]bool, error) {
+	r, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, xerrors.Errorf("unable to open git repository at %q: %w", dir, err)
+	}
+	refs, err := r.References()
+	if err != nil {
+		return nil, xerrors.Errorf("unable to list references: %w", err)
+	}
+	var heads []plumbing.Hash
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name()
+		if ref.Type() == plumbing.HashReference && (name.IsBranch() || name.IsRemote()) {
+			heads = append(heads, ref.Hash())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("unable to list branches: %w", err)
+	}
+	// Commits that were already visited are passed as seen, such that shared
+	// history is only walked once.
+	seen := make(map[plumbing.Hash]bool)
+	for _, head := range heads {
+		c, err := r.CommitObject(head)
+		if err != nil {
+			return nil, xerrors.Errorf("unable to resolve branch head %s: %w", head, err)
+		}
+		err = object.NewCommitPreorderIter(c, seen, nil).ForEach(func(c *object.Commit) error {
+			seen[c.Hash] = true
+			return nil
+		})
+		if err != nil {
+			return nil, xerrors.Errorf("unable to walk commit history: %w", err)
+		}
+	}
+	reachable := make(map[string]bool, len(seen))
+	for hash := range seen {
+		reachable[hash.String()] = true
+	}
+	return reachable, nil
+}
+
+// UnreachableCommits returns the given commits that exist in the git
+// repository at dir, but are not reachable from any local or remote branch.
+// Commits that are unknown to the repository, e.g., commits of other
+// repositories that share the workdir root, are never reported.
+func UnreachableCommits(dir string, commits []string) (map[string]bool, error) {
+	reachable, err := ReachableCommits(dir)
+	if err != nil {
+		return nil, err
+	}
+	r, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, xerrors.Errorf("unable to open git repository at %q: %w", dir, err)
+	}
+	unreachable := make(map[string]bool)
+	for _, commit := range commits {
+		if reachable[commit] || !isCommitHash(commit) {
+			continue
+		}
+		_, err := r.CommitObject(plumbing.NewHash(commit))
+		if err == plumbing.ErrObjectNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("unable to resolve commit %s: %w", commit, err)
+		}
+		unreachable[commit] = true
+	}
+	return unreachable, nil
+}
+
 // AddCrashers adds the crashers to the git repository.
 func AddCrashers(crashers, name, commit string) (bool, error) {
 	r, err := git.PlainOpenWithOptions(crashers, &git.PlainOpenOptions{DetectDotGit: true})
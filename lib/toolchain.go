@@ -0,0 +1,140 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// GoVersion returns the version of the local go toolchain, e.g., "go1.12.7".
+func GoVersion() (string, error) {
+	out, err := exec.Command("go", "version").Output()
+	if err != nil {
+		return "", xerrors.Errorf("unable to determine go version: %w", err)
+	}
+	// Output has the format "go version go1.12.7 linux/amd64".
+	fields := strings.Fields(string(out))
+	if len(fields) < 3 {
+		return "", xerrors.Errorf("unexpected go version output: %q", out)
+	}
+	return fields[2], nil
+}
+
+// GoFuzzBuildVersion returns the version of the go-fuzz-build binary on the
+// PATH. go-fuzz-build does not report a version, the content hash of the
+// binary identifies it instead.
+func GoFuzzBuildVersion() (string, error) {
+	path, err := exec.LookPath("go-fuzz-build")
+	if err != nil {
+		return "", xerrors.Errorf("unable to find go-fuzz-build: %w", err)
+	}
+	sum, err := fileHash(path)
+	if err != nil {
+		return "", xerrors.Errorf("unable to determine go-fuzz-build version: %w", err)
+	}
+	return sum, nil
+}
+
+// buildEnvVars are the go env variables that affect the fuzzing binary.
+var buildEnvVars = []string{
+	"GOOS", "GOARCH", "GOFLAGS", "GOEXPERIMENT", "CGO_ENABLED",
+	"GO386", "GOAMD64", "GOARM", "GOARM64", "GOMIPS", "GOMIPS64", "GOPPC64", "GOWASM",
+	"CC", "CXX", "CGO_CFLAGS", "CGO_CPPFLAGS", "CGO_CXXFLAGS", "CGO_LDFLAGS",
+}
+
+// GoEnv returns the effective values of the go env variables that affect the
+// fuzzing binary in the environment, as KEY=value pairs. Variables unknown
+// to the local toolchain have an empty value.
+func GoEnv(env []string) ([]string, error) {
+	cmd := exec.Command("go", append([]string{"env"}, buildEnvVars...)...)
+	cmd.Env = env
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, xerrors.Errorf("unable to determine go env: %w", err)
+	}
+	values := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	if len(values) != len(buildEnvVars) {
+		return nil, xerrors.Errorf("unexpected go env output: %q", out)
+	}
+	vars := make([]string, 0, len(buildEnvVars))
+	for i, name := range buildEnvVars {
+		vars = append(vars, name+"="+values[i])
+	}
+	return vars, nil
+}
+
+// CheckGoVersion checks that the local go toolchain satisfies the required
+// version, e.g., "1.11". An empty version is always satisfied.
+func CheckGoVersion(required string) error {
+	if required == "" {
+		return nil
+	}
+	want, err := parseGoVersion(required)
+	if err != nil {
+		return err
+	}
+	local, err := GoVersion()
+	if err != nil {
+		return err
+	}
+	have, err := parseGoVersion(local)
+	if err != nil {
+		return err
+	}
+	for i := range want {
+		if have[i] != want[i] {
+			if have[i] < want[i] {
+				return xerrors.Errorf("go %s required, but local toolchain is %s",
+					required, local)
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// parseGoVersion parses versions of the form "1.11", "1.11.2" and
+// "go1.11.2". Pre-release suffixes, e.g., "beta1" are ignored.
+func parseGoVersion(version string) ([3]int, error) {
+	var parsed [3]int
+	parts := strings.SplitN(strings.TrimPrefix(version, "go"), ".", 3)
+	for i, part := range parts {
+		if end := strings.IndexFunc(part, isNotDigit); end >= 0 {
+			part = part[:end]
+		}
+		v, err := strconv.Atoi(part)
+		if err != nil {
+			return parsed, xerrors.Errorf("invalid go version %q", version)
+		}
+		parsed[i] = v
+	}
+	return parsed, nil
+}
+
+func isNotDigit(r rune) bool {
+	return r < '0' || r > '9'
+}
@@ -0,0 +1,75 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Watchdog detects when go-fuzz stalls, i.e., when it stops reporting status
+// lines, or when the reported exec rate stays at zero. It is safe for
+// concurrent use.
+type Watchdog struct {
+	// Stall is the duration without progress after which go-fuzz is
+	// considered to have stalled. If it is not set, stalls are not detected.
+	Stall time.Duration
+
+	mtx      sync.Mutex
+	status   time.Time
+	progress time.Time
+}
+
+// Reset restarts the stall detection, e.g., when go-fuzz is (re)started.
+func (w *Watchdog) Reset(now time.Time) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	w.status, w.progress = now, now
+}
+
+// Observe records the status.
+func (w *Watchdog) Observe(s Status) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	w.status = s.Time
+	if s.ExecsPerSec > 0 {
+		w.progress = s.Time
+	}
+}
+
+// Stalled indicates whether go-fuzz has not made progress for the stall
+// duration, and describes why.
+func (w *Watchdog) Stalled(now time.Time) (string, bool) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.Stall <= 0 || now.Sub(w.progress) < w.Stall {
+		return "", false
+	}
+	if since := now.Sub(w.status); since >= w.Stall {
+		return fmt.Sprintf("no status reported for %s (stall %s)",
+			since.Round(time.Second), w.Stall), true
+	}
+	return fmt.Sprintf("execs/sec has been 0 for %s (stall %s)",
+		now.Sub(w.progress).Round(time.Second), w.Stall), true
+}
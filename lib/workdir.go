@@ -0,0 +1,220 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/oncilla/fuzzinator/conf"
+)
+
+// WorkdirMeta describes the state of a workdir set up by fuzzinator.
+type WorkdirMeta struct {
+	Target string `json:"target"`
+	Commit string `json:"commit"`
+	// ConfigHash is the hash of the target configuration the workdir was set
+	// up with, see ConfigHash.
+	ConfigHash string `json:"config_hash"`
+	// BuildKey is the build key of the fuzzing binary.
+	BuildKey string    `json:"build_key"`
+	Created  time.Time `json:"created"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+// ConfigHash returns the hash of the target configuration that determines the
+// workdir content, i.e., the corpus and the harness entry point. Engine
+// options and build settings are not included, as they do not affect the
+// corpus. The fuzzing binary is tracked separately by the build key.
+func ConfigHash(target conf.Target) string {
+	raw, err := json.Marshal(struct {
+		Name     string
+		Corpus   string
+		Package  string
+		Function string
+	}{target.Name, target.Corpus, target.Harness.Package, target.Harness.Function})
+	if err != nil {
+		// Marshalling plain config values cannot fail.
+		panic(err)
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// WorkdirMetaPath returns the path of the metadata file in the workdir.
+func WorkdirMetaPath(workdir string) string {
+	return filepath.Join(workdir, "fuzzinator.json")
+}
+
+// ReadWorkdirMeta reads the metadata of the workdir.
+func ReadWorkdirMeta(workdir string) (WorkdirMeta, error) {
+	var meta WorkdirMeta
+	raw, err := ioutil.ReadFile(WorkdirMetaPath(workdir))
+	if err != nil {
+		return meta, xerrors.Errorf("unable to read workdir metadata: %w", err)
+	}
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return meta, xerrors.Errorf("unable to decode workdir metadata: %w", err)
+	}
+	return meta, nil
+}
+
+// WriteWorkdirMeta writes the metadata of the workdir.
+func WriteWorkdirMeta(workdir string, meta WorkdirMeta) error {
+	raw, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return xerrors.Errorf("unable to encode workdir metadata: %w", err)
+	}
+	if err := ioutil.WriteFile(WorkdirMetaPath(workdir), raw, 0644); err != nil {
+		return xerrors.Errorf("unable to write workdir metadata: %w", err)
+	}
+	return nil
+}
+
+// CompatibleWorkdir checks whether the workdir was set up for the same
+// target, commit and configuration. If so, its corpus can be resumed.
+func CompatibleWorkdir(workdir string, target conf.Target, commit string) (WorkdirMeta, bool) {
+	meta, err := ReadWorkdirMeta(workdir)
+	if err != nil {
+		return meta, false
+	}
+	ok := meta.Target == target.Name && meta.Commit == commit &&
+		meta.ConfigHash == ConfigHash(target)
+	return meta, ok
+}
+
+// BinaryUpToDate checks whether the fuzzing binary in the workdir was built
+// with the given build configuration.
+func BinaryUpToDate(workdir string, cfg BuildConfig) bool {
+	if _, err := os.Stat(BinaryPath(workdir)); err != nil {
+		return false
+	}
+	built, err := ReadBuildConfig(BuildConfigPath(workdir))
+	if err != nil {
+		return false
+	}
+	return built.Key() == cfg.Key()
+}
+
+// WorkdirInfo summarizes a workdir.
+type WorkdirInfo struct {
+	Path   string
+	Target string
+	Commit string
+	// Meta is the workdir metadata. It is nil for workdirs that were set up by
+	// older versions of fuzzinator.
+	Meta *WorkdirMeta
+	// Size is the total size of the workdir in bytes.
+	Size int64
+	// Corpus is the number of corpus entries.
+	Corpus int
+	// Crashers is the number of distinct crashers.
+	Crashers int
+	// LastUsed is the last time the workdir was set up, fuzzed, or written to
+	// by go-fuzz.
+	LastUsed time.Time
+}
+
+// ListWorkdirs returns information about all workdirs in the workdir root,
+// sorted by path.
+func ListWorkdirs() ([]WorkdirInfo, error) {
+	entries, err := ioutil.ReadDir(WorkdirRoot())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("unable to list workdirs: %w", err)
+	}
+	var infos []WorkdirInfo
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		info, err := InspectWorkdir(filepath.Join(WorkdirRoot(), entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// InspectWorkdir returns information about the workdir.
+func InspectWorkdir(workdir string) (WorkdirInfo, error) {
+	info := WorkdirInfo{Path: workdir}
+	if meta, err := ReadWorkdirMeta(workdir); err == nil {
+		info.Meta = &meta
+		info.Target, info.Commit = meta.Target, meta.Commit
+		info.LastUsed = meta.LastUsed
+	} else {
+		name := filepath.Base(workdir)
+		if i := strings.LastIndex(name, "_"); i >= 0 {
+			info.Target, info.Commit = name[:i], name[i+1:]
+		}
+	}
+	err := filepath.Walk(workdir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.ModTime().After(info.LastUsed) {
+			info.LastUsed = fi.ModTime()
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		info.Size += fi.Size()
+		switch filepath.Dir(path) {
+		case CorpusDir(workdir):
+			info.Corpus++
+		case CrashersDir(workdir):
+			// Every crasher consists of the input, and the .quoted and
+			// .output files.
+			if filepath.Ext(path) == "" {
+				info.Crashers++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return info, xerrors.Errorf("unable to inspect workdir %s: %w", workdir, err)
+	}
+	return info, nil
+}
+
+// TouchWorkdir updates the last use of the workdir metadata, if present.
+func TouchWorkdir(workdir string) error {
+	meta, err := ReadWorkdirMeta(workdir)
+	if err != nil {
+		return nil
+	}
+	meta.LastUsed = time.Now()
+	return WriteWorkdirMeta(workdir, meta)
+}
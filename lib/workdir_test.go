@@ -0,0 +1,110 @@
+// MIT License
+//
+// Copyright (c) 2019 Oncilla
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lib_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/oncilla/fuzzinator/conf"
+	"github.com/oncilla/fuzzinator/lib"
+)
+
+func TestInspectWorkdir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fuzzinator-workdir")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	commit := "08d71df58cfedc3e3fabb7b84008b1a36bf5dd03"
+	workdir := filepath.Join(dir, "fuzz_"+commit)
+	require.NoError(t, os.MkdirAll(lib.CorpusDir(workdir), 0755))
+	require.NoError(t, os.MkdirAll(lib.CrashersDir(workdir), 0755))
+	write := func(dir, name, content string) {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	write(lib.CorpusDir(workdir), "a", "aa")
+	write(lib.CorpusDir(workdir), "b", "bb")
+	write(lib.CrashersDir(workdir), "c", "c")
+	write(lib.CrashersDir(workdir), "c.quoted", `"c"`)
+	write(lib.CrashersDir(workdir), "c.output", "panic")
+
+	t.Run("without metadata", func(t *testing.T) {
+		info, err := lib.InspectWorkdir(workdir)
+		require.NoError(t, err)
+		assert.Nil(t, info.Meta)
+		assert.Equal(t, "fuzz", info.Target)
+		assert.Equal(t, commit, info.Commit)
+		assert.Equal(t, 2, info.Corpus)
+		assert.Equal(t, 1, info.Crashers)
+		assert.Equal(t, int64(13), info.Size)
+	})
+	t.Run("with metadata", func(t *testing.T) {
+		meta := lib.WorkdirMeta{Target: "other", Commit: commit,
+			LastUsed: time.Now().Add(time.Hour)}
+		require.NoError(t, lib.WriteWorkdirMeta(workdir, meta))
+		info, err := lib.InspectWorkdir(workdir)
+		require.NoError(t, err)
+		require.NotNil(t, info.Meta)
+		assert.Equal(t, "other", info.Target)
+		assert.True(t, info.LastUsed.Equal(meta.LastUsed))
+	})
+}
+
+func TestCompatibleWorkdir(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "fuzzinator-workdir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	commit := "08d71df58cfedc3e3fabb7b84008b1a36bf5dd03"
+	target := conf.Target{
+		Name:    "fuzz",
+		Corpus:  "corpus",
+		Harness: conf.Harness{Package: "github.com/oncilla/fuzzinator/test", Function: "Fuzz"},
+	}
+	meta := lib.WorkdirMeta{Target: target.Name, Commit: commit,
+		ConfigHash: lib.ConfigHash(target)}
+	require.NoError(t, lib.WriteWorkdirMeta(workdir, meta))
+
+	_, ok := lib.CompatibleWorkdir(workdir, target, commit)
+	assert.True(t, ok)
+
+	// Build settings only affect the binary, not the corpus.
+	rebuilt := target
+	rebuilt.Harness.BuildTags = "extra"
+	rebuilt.Harness.Build = conf.Build{Race: true, GCFlags: "-N -l"}
+	_, ok = lib.CompatibleWorkdir(workdir, rebuilt, commit)
+	assert.True(t, ok)
+
+	other := target
+	other.Harness.Function = "FuzzOther"
+	_, ok = lib.CompatibleWorkdir(workdir, other, commit)
+	assert.False(t, ok)
+	_, ok = lib.CompatibleWorkdir(workdir, target, "other")
+	assert.False(t, ok)
+}